@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/slack-go/slack"
+)
+
+// Defaults for changelogs.
+const (
+	DefaultChangelogLimit = 10
+	// Commits listed for a first release, which has no previous tag
+	DefaultChangelogMaxCommits = 50
+)
+
+// maxSectionText is the longest text of a Block Kit section.
+const maxSectionText = 3000
+
+// conventionalPattern matches Conventional Commit subjects like
+// "feat(api)!: add pagination".
+var conventionalPattern = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?: *(.+)$`)
+
+type (
+	// Changelog lists the commits of a tag build since the previous tag,
+	// grouped by Conventional Commit type.
+	Changelog struct {
+		From     string
+		To       string
+		Breaking []ChangelogEntry
+		Features []ChangelogEntry
+		Fixes    []ChangelogEntry
+		Other    []ChangelogEntry
+		Total    int
+		// Text is the changelog in mrkdwn, truncated to the configured limit
+		Text string
+	}
+
+	// ChangelogEntry is a commit of a changelog.
+	ChangelogEntry struct {
+		Hash     string
+		Short    string
+		Type     string
+		Scope    string
+		Subject  string
+		Author   string
+		Link     string
+		Breaking bool
+	}
+)
+
+// changelog collects the commits between the previous release and Build.Tag.
+// Without a previous release it lists the latest commits of the tag.
+func (p Plugin) changelog() (*Changelog, error) {
+	repo, err := git.PlainOpenWithOptions(p.gitRepoPath(), &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+
+	to, err := repo.ResolveRevision(plumbing.Revision(p.Build.Tag))
+	if err != nil {
+		// The tag may not have been fetched, the checkout is the tag
+		if to, err = repo.ResolveRevision(plumbing.Revision(plumbing.HEAD)); err != nil {
+			return nil, fmt.Errorf("could not resolve %s: %w", p.Build.Tag, err)
+		}
+	}
+
+	head, err := repo.CommitObject(*to)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", p.Build.Tag, err)
+	}
+
+	changelog := &Changelog{To: p.Build.Tag}
+	// The walk skips everything the previous release already contains,
+	// including the commits of branches merged after it
+	released := map[plumbing.Hash]bool{}
+	maxCommits := DefaultChangelogMaxCommits
+	if p.Release != nil && p.Release.Previous != "" {
+		from, err := repo.ResolveRevision(plumbing.Revision(p.Release.Previous))
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve %s: %w", p.Release.Previous, err)
+		}
+		if released, err = ancestors(repo, *from); err != nil {
+			return nil, fmt.Errorf("failed to get commit log of %s: %w", p.Release.Previous, err)
+		}
+		changelog.From = p.Release.Previous
+		maxCommits = 0
+	}
+
+	commits := object.NewCommitPreorderIter(head, released, nil)
+	defer commits.Close()
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		if maxCommits > 0 && changelog.Total == maxCommits {
+			return storer.ErrStop
+		}
+		// Merge commits only repeat the commits they merge
+		if c.NumParents() > 1 {
+			return nil
+		}
+		changelog.add(p.newChangelogEntry(c))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit log: %w", err)
+	}
+
+	changelog.Text = changelog.mrkdwn(p.changelogLimit())
+	return changelog, nil
+}
+
+// ancestors returns the commit and every commit reachable from it.
+func ancestors(repo *git.Repository, hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[plumbing.Hash]bool{}
+	commits := object.NewCommitPreorderIter(commit, nil, nil)
+	defer commits.Close()
+	err = commits.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	return seen, err
+}
+
+func (p Plugin) changelogLimit() int {
+	if p.Config.ChangelogLimit <= 0 {
+		return DefaultChangelogLimit
+	}
+	return p.Config.ChangelogLimit
+}
+
+func (p Plugin) newChangelogEntry(c *object.Commit) ChangelogEntry {
+	subject, body, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	entry := ChangelogEntry{
+		Hash:    c.Hash.String(),
+		Short:   c.Hash.String()[:7],
+		Subject: strings.TrimSpace(subject),
+		Author:  c.Author.Name,
+	}
+	if p.Repo.Link != "" {
+		entry.Link = strings.TrimSuffix(p.Repo.Link, "/") + "/commit/" + entry.Hash
+	}
+
+	if m := conventionalPattern.FindStringSubmatch(entry.Subject); m != nil {
+		entry.Type = strings.ToLower(m[1])
+		entry.Scope = m[2]
+		entry.Breaking = m[3] == "!"
+		entry.Subject = m[4]
+	}
+	if strings.Contains(body, "BREAKING CHANGE:") || strings.Contains(body, "BREAKING-CHANGE:") {
+		entry.Breaking = true
+	}
+	return entry
+}
+
+func (c *Changelog) add(entry ChangelogEntry) {
+	c.Total++
+	switch {
+	case entry.Breaking:
+		c.Breaking = append(c.Breaking, entry)
+	case entry.Type == "feat":
+		c.Features = append(c.Features, entry)
+	case entry.Type == "fix":
+		c.Fixes = append(c.Fixes, entry)
+	default:
+		c.Other = append(c.Other, entry)
+	}
+}
+
+// mrkdwn renders up to limit entries, breaking changes first, and notes how
+// many were left out.
+func (c *Changelog) mrkdwn(limit int) string {
+	var b strings.Builder
+	if c.From != "" {
+		fmt.Fprintf(&b, "*Changes since %s*", escapeMrkdwn(c.From))
+	} else {
+		b.WriteString("*Changes*")
+	}
+	if c.Total == 0 {
+		b.WriteString("\nNo changes")
+		return b.String()
+	}
+
+	shown := 0
+	for _, group := range []struct {
+		title   string
+		entries []ChangelogEntry
+	}{
+		{"Breaking changes", c.Breaking},
+		{"Features", c.Features},
+		{"Fixes", c.Fixes},
+		{"Other", c.Other},
+	} {
+		if len(group.entries) == 0 || shown == limit {
+			continue
+		}
+		fmt.Fprintf(&b, "\n*%s*", group.title)
+		for _, entry := range group.entries {
+			if shown == limit {
+				break
+			}
+			line := "\n• " + entry.mrkdwn()
+			// Leave room for the "and N more" line
+			if b.Len()+len(line) > maxSectionText-32 {
+				limit = shown
+				break
+			}
+			b.WriteString(line)
+			shown++
+		}
+	}
+	if more := c.Total - shown; more > 0 {
+		fmt.Fprintf(&b, "\n_and %d more_", more)
+	}
+	return b.String()
+}
+
+func (e ChangelogEntry) mrkdwn() string {
+	hash := "`" + e.Short + "`"
+	if e.Link != "" {
+		hash = "<" + e.Link + "|" + e.Short + ">"
+	}
+	subject := escapeMrkdwn(e.Subject)
+	if e.Scope != "" {
+		subject = "*" + escapeMrkdwn(e.Scope) + ":* " + subject
+	}
+	return hash + " " + subject
+}
+
+// block is the changelog as a Block Kit section.
+func (c *Changelog) block() slack.Block {
+	return slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, c.Text, false, false), nil, nil)
+}
+
+// insertBeforeActions adds block before trailing action blocks, so buttons
+// stay at the bottom of the message.
+func insertBeforeActions(blocks []slack.Block, block slack.Block) []slack.Block {
+	i := len(blocks)
+	for i > 0 && blocks[i-1].BlockType() == slack.MBTAction {
+		i--
+	}
+	blocks = append(blocks, nil)
+	copy(blocks[i+1:], blocks[i:])
+	blocks[i] = block
+	return blocks
+}
+
+// escapeMrkdwn escapes the characters mrkdwn uses for links and mentions.
+func escapeMrkdwn(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// newChangelogRepo creates a fixture repository with one commit per message.
+// Messages starting with "tag " tag the previous commit instead.
+func newChangelogRepo(t *testing.T, messages ...string) string {
+	t.Helper()
+
+	fixture := newGitFixture(t)
+	for _, message := range messages {
+		if tag, ok := strings.CutPrefix(message, "tag "); ok {
+			fixture.tag(tag)
+			continue
+		}
+		fixture.commit("octocat@github.com", message, nil)
+	}
+	return fixture.Dir
+}
+
+func getChangelogPlugin(dir, tag string) Plugin {
+	plugin := getTestPlugin()
+	plugin.Build.Event = "tag"
+	plugin.Build.Tag = tag
+	plugin.Config.GitPath = dir
+	plugin.Config.Changelog = true
+	plugin.Release = plugin.release()
+	return plugin
+}
+
+func TestChangelog(t *testing.T) {
+	dir := newChangelogRepo(t,
+		"feat: first release",
+		"tag v1.0.0",
+		"fix(api): handle empty pages",
+		"feat(ui): dark mode",
+		"chore: bump dependencies",
+		"refactor!: drop the v1 API",
+		"feat: webhooks\n\nBREAKING CHANGE: payloads are signed",
+		"Update <README> & docs",
+		"tag v2.0.0",
+		"fix: after the release",
+	)
+
+	plugin := getChangelogPlugin(dir, "v2.0.0")
+	changelog, err := plugin.changelog()
+	assert.NilError(t, err)
+
+	assert.Equal(t, changelog.From, "v1.0.0")
+	assert.Equal(t, changelog.To, "v2.0.0")
+	assert.Equal(t, changelog.Total, 6)
+
+	var subjects []string
+	for _, entry := range changelog.Breaking {
+		subjects = append(subjects, entry.Subject)
+	}
+	assert.DeepEqual(t, subjects, []string{"webhooks", "drop the v1 API"})
+	assert.Equal(t, len(changelog.Features), 1)
+	assert.Equal(t, changelog.Features[0].Scope, "ui")
+	assert.Equal(t, len(changelog.Fixes), 1)
+	assert.Equal(t, len(changelog.Other), 2)
+
+	lines := strings.Split(changelog.Text, "\n")
+	assert.Equal(t, lines[0], "*Changes since v1.0.0*")
+	assert.Equal(t, lines[1], "*Breaking changes*")
+	// Newest first, with mrkdwn escaped
+	assert.Equal(t, lines[len(lines)-2], "• `"+changelog.Other[0].Short+"` Update &lt;README&gt; &amp; docs")
+}
+
+func TestChangelogLinksAndLimit(t *testing.T) {
+	dir := newChangelogRepo(t, "feat: one", "tag v1.0.0", "fix: two", "fix(api): three", "feat: four", "tag v1.1.0")
+
+	plugin := getChangelogPlugin(dir, "v1.1.0")
+	plugin.Repo.Link = "https://github.com/octocat/hello-world/"
+	plugin.Config.ChangelogLimit = 2
+	changelog, err := plugin.changelog()
+	assert.NilError(t, err)
+
+	feature := changelog.Features[0]
+	assert.Equal(t, feature.Link, "https://github.com/octocat/hello-world/commit/"+feature.Hash)
+	assert.Equal(t, changelog.Text, strings.Join([]string{
+		"*Changes since v1.0.0*",
+		"*Features*",
+		"• <" + feature.Link + "|" + feature.Short + "> four",
+		"*Fixes*",
+		"• <" + changelog.Fixes[0].Link + "|" + changelog.Fixes[0].Short + "> *api:* three",
+		"_and 1 more_",
+	}, "\n"))
+}
+
+func TestChangelogMergedBranch(t *testing.T) {
+	fixture := newGitFixture(t)
+	fixture.commit("octocat@github.com", "root", nil)
+	base := fixture.commit("octocat@github.com", "base before v1", nil)
+	fixture.commit("octocat@github.com", "feat: one", nil)
+	fixture.tag("v1.0.0")
+
+	// A branch started before v1.0.0 and merged after it
+	fixture.checkout(base)
+	side := fixture.commit("octocat@github.com", "fix: side branch", nil)
+	fixture.checkout("master")
+	fixture.commit("octocat@github.com", "feat: two", nil)
+	fixture.merge("octocat@github.com", "Merge branch 'side'", side)
+	fixture.tag("v1.1.0")
+
+	plugin := getChangelogPlugin(fixture.Dir, "v1.1.0")
+	changelog, err := plugin.changelog()
+	assert.NilError(t, err)
+
+	// Only the commits v1.0.0 doesn't contain
+	assert.Equal(t, changelog.From, "v1.0.0")
+	assert.Equal(t, changelog.Total, 2)
+	assert.Equal(t, changelog.Features[0].Subject, "two")
+	assert.Equal(t, changelog.Fixes[0].Subject, "side branch")
+}
+
+func TestChangelogWithoutTags(t *testing.T) {
+	dir := newChangelogRepo(t, "feat: one", "fix: two")
+
+	// The tag of the build wasn't fetched, so the checkout is used
+	plugin := getChangelogPlugin(dir, "v1.0.0")
+	changelog, err := plugin.changelog()
+	assert.NilError(t, err)
+	assert.Equal(t, changelog.From, "")
+	assert.Equal(t, changelog.Total, 2)
+	assert.Assert(t, strings.HasPrefix(changelog.Text, "*Changes*\n"))
+}
+
+func TestChangelogMessage(t *testing.T) {
+	dir := newChangelogRepo(t, "feat: one", "tag v1.0.0", "fix: two", "tag v1.0.1")
+
+	var body struct {
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	plugin := getChangelogPlugin(dir, "v1.0.1")
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomTemplate = "success_tagged_deploy_1"
+	assert.NilError(t, plugin.Exec())
+
+	// The changelog goes before the buttons
+	var types []string
+	for _, block := range body.Blocks {
+		var b struct {
+			Type string `json:"type"`
+		}
+		assert.NilError(t, json.Unmarshal(block, &b))
+		types = append(types, b.Type)
+	}
+	assert.DeepEqual(t, types, []string{"header", "section", "section", "section", "actions"})
+	assert.Assert(t, strings.Contains(string(body.Blocks[3]), "Changes since v1.0.0"), string(body.Blocks[3]))
+}
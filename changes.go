@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/slack-go/slack"
+)
+
+// Defaults for change summaries.
+const (
+	DefaultChangesDepth = 1
+	DefaultChangesLimit = 5
+)
+
+// rootGroup is the group of files at the top of the repository.
+const rootGroup = "(root)"
+
+// maxDiffFiles bounds the files whose lines are counted, so a large diff,
+// like a vendored dependency update, doesn't diff every file.
+const maxDiffFiles = 1000
+
+type (
+	// ChangeSummary counts the changed files and lines of a build per
+	// directory.
+	ChangeSummary struct {
+		From       string
+		To         string
+		Files      int
+		Insertions int
+		Deletions  int
+		// Lines were only counted for the first files of a large diff
+		Partial bool
+		// Largest groups first
+		Groups []ChangeGroup
+		// Text is the summary in mrkdwn, truncated to the configured limit
+		Text string
+	}
+
+	// ChangeGroup is the changes to one directory or configured group.
+	ChangeGroup struct {
+		Name       string
+		Files      int
+		Insertions int
+		Deletions  int
+	}
+
+	// changeRule puts files matching a pattern in a named group.
+	changeRule struct {
+		name    string
+		pattern string
+	}
+)
+
+// changes summarises the diff of the build: from DRONE_COMMIT_BEFORE, or
+// the previous release of tag builds, or else the parent of the commit.
+func (p Plugin) changes() (*ChangeSummary, error) {
+	rules, err := parseChangeRules(p.Config.ChangesGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := git.PlainOpenWithOptions(p.gitRepoPath(), &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+
+	to, err := resolveCommit(repo, p.Build.Commit, plumbing.HEAD.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var from *object.Commit
+	var fromRev string
+	if p.Release != nil && p.Release.Previous != "" {
+		fromRev = p.Release.Previous
+	}
+	for _, rev := range []string{p.Build.Before, fromRev} {
+		if rev == "" || strings.Trim(rev, "0") == "" {
+			continue
+		}
+		if from, err = resolveCommit(repo, rev); err == nil {
+			break
+		}
+	}
+	// The first commit is diffed against nothing
+	if from == nil && to.NumParents() > 0 {
+		if from, err = to.Parent(0); err != nil {
+			return nil, fmt.Errorf("failed to get parent commit: %w", err)
+		}
+	}
+
+	summary := &ChangeSummary{To: to.Hash.String()}
+	var fromTree *object.Tree
+	if from != nil {
+		summary.From = from.Hash.String()
+		if fromTree, err = from.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to get tree of %s: %w", from.Hash, err)
+		}
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of %s: %w", to.Hash, err)
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s: %w", to.Hash, err)
+	}
+
+	// Diff one file at a time, so only its patch is held in memory
+	groups := map[string]*ChangeGroup{}
+	for i, change := range changes {
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		groupName := changeGroupName(name, rules, p.changesDepth())
+		group, ok := groups[groupName]
+		if !ok {
+			group = &ChangeGroup{Name: groupName}
+			groups[groupName] = group
+		}
+		group.Files++
+		summary.Files++
+
+		if i >= maxDiffFiles {
+			summary.Partial = true
+			continue
+		}
+		patch, err := change.Patch()
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", name, err)
+		}
+		for _, stat := range patch.Stats() {
+			group.Insertions += stat.Addition
+			group.Deletions += stat.Deletion
+			summary.Insertions += stat.Addition
+			summary.Deletions += stat.Deletion
+		}
+	}
+	for _, group := range groups {
+		summary.Groups = append(summary.Groups, *group)
+	}
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		a, b := summary.Groups[i], summary.Groups[j]
+		if a.Insertions+a.Deletions != b.Insertions+b.Deletions {
+			return a.Insertions+a.Deletions > b.Insertions+b.Deletions
+		}
+		return a.Name < b.Name
+	})
+
+	summary.Text = summary.mrkdwn(p.changesLimit())
+	return summary, nil
+}
+
+func (p Plugin) changesDepth() int {
+	if p.Config.ChangesDepth <= 0 {
+		return DefaultChangesDepth
+	}
+	return p.Config.ChangesDepth
+}
+
+func (p Plugin) changesLimit() int {
+	if p.Config.ChangesLimit <= 0 {
+		return DefaultChangesLimit
+	}
+	return p.Config.ChangesLimit
+}
+
+// resolveCommit returns the commit of the first revision that resolves.
+func resolveCommit(repo *git.Repository, revs ...string) (*object.Commit, error) {
+	err := plumbing.ErrReferenceNotFound
+	for _, rev := range revs {
+		if rev == "" {
+			continue
+		}
+		var hash *plumbing.Hash
+		if hash, err = repo.ResolveRevision(plumbing.Revision(rev)); err == nil {
+			return repo.CommitObject(*hash)
+		}
+	}
+	return nil, fmt.Errorf("could not resolve %s: %w", strings.Join(revs, " or "), err)
+}
+
+// parseChangeRules reads comma separated name=pattern rules. A name can be
+// given several patterns.
+func parseChangeRules(s string) ([]changeRule, error) {
+	var rules []changeRule
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, pattern, ok := strings.Cut(rule, "=")
+		name, pattern = strings.TrimSpace(name), strings.Trim(strings.TrimSpace(pattern), "/")
+		if !ok || name == "" || pattern == "" {
+			return nil, fmt.Errorf("invalid change group %q, must be name=pattern", rule)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid change group pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, changeRule{name: name, pattern: pattern})
+	}
+	return rules, nil
+}
+
+// changeGroupName returns the group of the first rule matching the file or
+// one of its directories, or else its directory up to depth levels deep.
+func changeGroupName(file string, rules []changeRule, depth int) string {
+	for _, rule := range rules {
+		if matchesPath(rule.pattern, file) {
+			return rule.name
+		}
+	}
+
+	dirs := strings.Split(path.Dir(file), "/")
+	if dirs[0] == "." {
+		return rootGroup
+	}
+	if len(dirs) > depth {
+		dirs = dirs[:depth]
+	}
+	return strings.Join(dirs, "/") + "/"
+}
+
+// matchesPath reports whether pattern matches file or a directory it is in.
+func matchesPath(pattern, file string) bool {
+	for name := file; name != "." && name != "/"; name = path.Dir(name) {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mrkdwn renders up to limit groups on one line.
+func (s *ChangeSummary) mrkdwn(limit int) string {
+	if s.Files == 0 {
+		return "No files changed"
+	}
+
+	var parts []string
+	for i, group := range s.Groups {
+		if i == limit {
+			parts = append(parts, fmt.Sprintf("and %d more", len(s.Groups)-limit))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("`%s` %s +%d −%d", escapeMrkdwn(group.Name), plural(group.Files, "file"), group.Insertions, group.Deletions))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// block is the summary as a Block Kit context block.
+func (s *ChangeSummary) block() slack.Block {
+	return slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, s.Text, false, false))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
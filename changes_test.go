@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// newChangesRepo creates a repository with a base commit and a commit that
+// changes several directories. It returns the hash of the base commit.
+func newChangesRepo(t *testing.T) (*gitFixture, string) {
+	t.Helper()
+
+	fixture := newGitFixture(t)
+	before := fixture.commit("octocat@github.com", "add files", map[string]string{
+		"README.md":           "hello\n",
+		"api/server.go":       "package api\n",
+		"web/src/app.ts":      "export {}\n",
+		"services/auth/go.go": "package auth\n",
+	})
+	fixture.commit("octocat@github.com", "change files", map[string]string{
+		"README.md":              "hello\nworld\n",
+		"api/server.go":          "package api\n\nfunc Serve() {}\n",
+		"api/handlers.go":        "package api\n\nfunc Handle() {}\n",
+		"web/src/app.ts":         "export const app = 1\n",
+		"services/auth/go.go":    "package auth\n\n// Auth\n",
+		"services/billing/go.go": "package billing\n",
+	})
+	return fixture, before
+}
+
+func TestChangesPartial(t *testing.T) {
+	fixture := newGitFixture(t)
+	fixture.commit("octocat@github.com", "add readme", map[string]string{"README.md": "hello\n"})
+
+	files := map[string]string{}
+	for i := 0; i <= maxDiffFiles; i++ {
+		files[fmt.Sprintf("vendor/%04d.go", i)] = "package vendor\n"
+	}
+	fixture.commit("octocat@github.com", "vendor dependencies", files)
+
+	plugin := getTestPlugin()
+	plugin.Config.GitPath = fixture.Dir
+	summary, err := plugin.changes()
+	assert.NilError(t, err)
+
+	// Every file counts, but only the lines of the first files
+	assert.Equal(t, summary.Files, maxDiffFiles+1)
+	assert.Equal(t, summary.Insertions, maxDiffFiles)
+	assert.Assert(t, summary.Partial)
+}
+
+func TestChanges(t *testing.T) {
+	fixture, _ := newChangesRepo(t)
+
+	plugin := getTestPlugin()
+	plugin.Config.GitPath = fixture.Dir
+	changes, err := plugin.changes()
+	assert.NilError(t, err)
+
+	assert.Equal(t, changes.Files, 6)
+	assert.Equal(t, changes.Insertions, 10)
+	assert.Equal(t, changes.Deletions, 1)
+	assert.DeepEqual(t, changes.Groups, []ChangeGroup{
+		{Name: "api/", Files: 2, Insertions: 5, Deletions: 0},
+		{Name: "services/", Files: 2, Insertions: 3, Deletions: 0},
+		{Name: "web/", Files: 1, Insertions: 1, Deletions: 1},
+		{Name: rootGroup, Files: 1, Insertions: 1, Deletions: 0},
+	})
+	assert.Equal(t, changes.Text, "`api/` 2 files +5 −0 · `services/` 2 files +3 −0 · `web/` 1 file +1 −1 · `(root)` 1 file +1 −0")
+}
+
+func TestChangesGrouping(t *testing.T) {
+	fixture, _ := newChangesRepo(t)
+
+	plugin := getTestPlugin()
+	plugin.Config.GitPath = fixture.Dir
+	plugin.Config.ChangesGroups = "Frontend=web, Docs=*.md"
+	plugin.Config.ChangesDepth = 2
+	plugin.Config.ChangesLimit = 2
+	changes, err := plugin.changes()
+	assert.NilError(t, err)
+
+	var names []string
+	for _, group := range changes.Groups {
+		names = append(names, group.Name)
+	}
+	assert.DeepEqual(t, names, []string{"api/", "Frontend", "services/auth/", "Docs", "services/billing/"})
+	assert.Equal(t, changes.Text, "`api/` 2 files +5 −0 · `Frontend` 1 file +1 −1 · and 3 more")
+
+	plugin.Config.ChangesGroups = "Frontend"
+	_, err = plugin.changes()
+	assert.ErrorContains(t, err, "must be name=pattern")
+}
+
+func TestChangesRange(t *testing.T) {
+	fixture, before := newChangesRepo(t)
+	fixture.commit("octocat@github.com", "add guide", map[string]string{"docs/guide.md": "guide\n"})
+
+	plugin := getTestPlugin()
+	plugin.Config.GitPath = fixture.Dir
+
+	// The last commit only
+	changes, err := plugin.changes()
+	assert.NilError(t, err)
+	assert.Equal(t, changes.Files, 1)
+
+	// Everything pushed since the commit before the push
+	plugin.Build.Before = before
+	changes, err = plugin.changes()
+	assert.NilError(t, err)
+	assert.Equal(t, changes.From, before)
+	assert.Equal(t, changes.Files, 7)
+
+	// A new branch has no commit before
+	plugin.Build.Before = strings.Repeat("0", 40)
+	changes, err = plugin.changes()
+	assert.NilError(t, err)
+	assert.Equal(t, changes.Files, 1)
+}
+
+func TestChangesBlock(t *testing.T) {
+	fixture, _ := newChangesRepo(t)
+
+	var body struct {
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.GitPath = fixture.Dir
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomTemplate = "basic_success_1"
+	plugin.Config.ChangesBlock = true
+	plugin.Config.ChangesLimit = 1
+	assert.NilError(t, plugin.Exec())
+
+	// The summary goes before the buttons
+	context := string(body.Blocks[len(body.Blocks)-2])
+	assert.Assert(t, strings.Contains(context, `"type":"context"`), context)
+	assert.Assert(t, strings.Contains(context, "`api/` 2 files +5 −0 · and 3 more"), context)
+}
+
+func TestContextBlockTemplate(t *testing.T) {
+	var blocks []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Blocks []map[string]interface{} `json:"blocks"`
+		}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+		blocks = body.Blocks
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "templates", "basic_success.json"), []byte(`{"blocks": [
+		{"type": "context", "elements": [{"type": "mrkdwn", "text": "{{.Repo.Name}}"}]}
+	]}`), 0644))
+	wd, err := os.Getwd()
+	assert.NilError(t, err)
+	assert.NilError(t, os.Chdir(dir))
+	defer func() { assert.NilError(t, os.Chdir(wd)) }()
+
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomTemplate = "basic_success_1"
+	assert.NilError(t, plugin.Exec())
+
+	assert.Equal(t, len(blocks), 1)
+	assert.Equal(t, blocks[0]["type"], "context")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// DefaultHTTPTimeout bounds every request the plugin makes.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// newHTTPClient returns the client for Slack, webhooks and template
+// fetching, configured with the proxy, CA bundle, timeout and User-Agent of
+// config. Without a proxy the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables apply.
+func newHTTPClient(config Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.Proxy != "" {
+		proxy, err := url.Parse(config.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q", config.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if config.CACert != "" {
+		pem, err := os.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca cert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if config.UserAgent != "" {
+		roundTripper = userAgentTransport{userAgent: config.UserAgent, next: transport}
+	}
+
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   config.HTTPTimeout,
+	}, nil
+}
+
+// userAgentTransport sets the User-Agent of every request.
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests must not be modified by a RoundTripper
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.next.RoundTrip(req)
+}
+
+// httpClient returns the client set up by Exec, or the default client when
+// the plugin is used without it.
+func (p Plugin) httpClient() *http.Client {
+	if p.client == nil {
+		return http.DefaultClient
+	}
+	return p.client
+}
+
+// slackClient returns a Web API client for the access token.
+func (p Plugin) slackClient() *slack.Client {
+	return p.slackClientFor(p.Config.AccessToken)
+}
+
+// slackClientFor returns a Web API client for token that talks to
+// Config.APIURL.
+func (p Plugin) slackClientFor(token string) *slack.Client {
+	return slack.New(token,
+		slack.OptionAPIURL(p.apiURL()),
+		slack.OptionHTTPClient(p.httpClient()),
+	)
+}
+
+// apiURL is the base URL of the Slack Web API, with the trailing slash the
+// client expects.
+func (p Plugin) apiURL() string {
+	if p.Config.APIURL == "" {
+		return slack.APIURL
+	}
+	return strings.TrimSuffix(p.Config.APIURL, "/") + "/"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestHTTPClientUserAgent(t *testing.T) {
+	fake := newFakeSlack(t)
+	var (
+		mu     sync.Mutex
+		agents []string
+	)
+	fake.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents = append(agents, r.UserAgent())
+		mu.Unlock()
+		fake.handle(w, r)
+	})
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.UserAgent = "drone-slack-test"
+	assert.NilError(t, plugin.Exec())
+
+	assert.Assert(t, len(agents) > 0)
+	for _, agent := range agents {
+		assert.Equal(t, agent, "drone-slack-test")
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Plain HTTP requests through a proxy carry the absolute URL
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = "http://hooks.example.com/services/T000/B000/XXX"
+	plugin.Config.Proxy = proxy.URL
+	assert.NilError(t, plugin.Exec())
+
+	assert.DeepEqual(t, proxied, []string{"http://hooks.example.com/services/T000/B000/XXX"})
+}
+
+func TestHTTPClientCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{{build.status}} from a private host"))
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.Template = server.URL + "/template.hbs"
+
+	// The test server's certificate isn't trusted by default
+	_, err := templateMessage(plugin.Config.Template, plugin)
+	assert.ErrorContains(t, err, "certificate")
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NilError(t, os.WriteFile(caCert, cert, 0644))
+
+	plugin.Config.CACert = caCert
+	plugin.client, err = newHTTPClient(plugin.Config)
+	assert.NilError(t, err)
+
+	msg, err := templateMessage(plugin.Config.Template, plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "success from a private host")
+}
+
+func TestHTTPClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := newHTTPClient(Config{HTTPTimeout: 50 * time.Millisecond})
+	assert.NilError(t, err)
+	assert.ErrorContains(t, postJSON(client, server.URL, map[string]string{}), "Client.Timeout")
+}
+
+func TestHTTPClientInvalid(t *testing.T) {
+	_, err := newHTTPClient(Config{Proxy: "not a url"})
+	assert.ErrorContains(t, err, "invalid proxy url")
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NilError(t, os.WriteFile(caCert, []byte("not a certificate"), 0644))
+	_, err = newHTTPClient(Config{CACert: caCert})
+	assert.ErrorContains(t, err, "no certificates found")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Duration is how long the build ran, or has been running so far, like
+// 1m23s. Templates would render a time.Duration as nanoseconds.
+func (b Build) Duration() string {
+	return elapsed(b.Started, b.Finished).String()
+}
+
+// Duration is how long the job ran, or has been running so far.
+func (j Job) Duration() string {
+	return elapsed(j.Started, j.Finished).String()
+}
+
+// Duration is how long the stage ran, or has been running so far.
+func (s Stage) Duration() string {
+	return elapsed(s.Started, s.Finished).String()
+}
+
+// elapsed is the time between two Unix timestamps, with now standing in
+// for an unset end.
+func elapsed(started, finished int64) time.Duration {
+	if started == 0 {
+		return 0
+	}
+	if finished == 0 {
+		finished = time.Now().Unix()
+	}
+	if finished < started {
+		return 0
+	}
+	return time.Duration(finished-started) * time.Second
+}
+
+// sensitiveEnv are parts of variable names that hold credentials, which
+// templates from remote sources must not be able to post to Slack.
+var sensitiveEnv = []string{"PASSWORD", "TOKEN", "SECRET", "CREDENTIAL", "PRIVATE_KEY"}
+
+// droneEnv returns the DRONE_* variables of environ, without the netrc
+// credentials and anything named like a secret.
+func droneEnv(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(key, "DRONE_") && !isSensitiveEnv(key) {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+func isSensitiveEnv(key string) bool {
+	if strings.HasPrefix(key, "DRONE_NETRC_") {
+		return true
+	}
+	key = strings.ToUpper(key)
+	for _, part := range sensitiveEnv {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
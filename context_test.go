@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestTemplateContext(t *testing.T) {
+	plugin := getTestPlugin()
+	plugin.Repo.Link = "https://github.com/octocat/hello-world"
+	plugin.Build.Started = 1700000000
+	plugin.Build.Finished = 1700000083
+	plugin.Build.CommitLink = "https://github.com/octocat/hello-world/commit/7fd1a60"
+	plugin.Build.SourceBranch = "feature"
+	plugin.Build.TargetBranch = "main"
+	plugin.Build.FailedSteps = []string{"test", "lint"}
+	plugin.Build.Semver = Semver{Version: "1.2.3-rc.1", Major: "1", Prerelease: "rc.1"}
+	plugin.Build.Calver = "24.01.1"
+	plugin.Stage = Stage{Name: "default", Number: 1, OS: "linux", Arch: "amd64", Started: 1700000010, Finished: 1700000040}
+	plugin.Step = Step{Name: "notify", Number: 4}
+	plugin.Env = droneEnv([]string{"DRONE_RUNNER_HOSTNAME=runner-1", "HOME=/root"})
+
+	tests := []struct {
+		template, want string
+	}{
+		{"{{repo.link}}", "https://github.com/octocat/hello-world"},
+		{"{{build.commitLink}}", "https://github.com/octocat/hello-world/commit/7fd1a60"},
+		{"{{build.sourceBranch}} -> {{build.targetBranch}}", "feature -> main"},
+		{"{{#each build.failedSteps}}[{{this}}]{{/each}}", "[test][lint]"},
+		{"{{build.semver.major}} {{build.semver.prerelease}} {{build.calver}}", "1 rc.1 24.01.1"},
+		{"{{build.duration}}", "1m23s"},
+		{"{{stage.name}}#{{stage.number}} {{stage.OS}}/{{stage.arch}} {{stage.duration}}", "default#1 linux/amd64 30s"},
+		{"{{step.name}}#{{step.number}}", "notify#4"},
+		{"{{env.DRONE_RUNNER_HOSTNAME}}{{env.HOME}}", "runner-1"},
+	}
+	for _, test := range tests {
+		msg, err := templateMessage("inline:"+test.template, plugin)
+		assert.NilError(t, err, test.template)
+		assert.Equal(t, msg, test.want, test.template)
+	}
+}
+
+func TestDroneEnv(t *testing.T) {
+	env := droneEnv([]string{
+		"DRONE_RUNNER_HOSTNAME=runner-1",
+		"DRONE_BUILD_NUMBER=42",
+		"DRONE_NETRC_MACHINE=github.com",
+		"DRONE_NETRC_USERNAME=octocat",
+		"DRONE_NETRC_PASSWORD=hunter2",
+		"DRONE_GIT_TOKEN=ghp_secret",
+		"DRONE_VAULT_SECRET=s3cr3t",
+		"DRONE_DB_Password=hunter2",
+		"HOME=/root",
+	})
+	assert.DeepEqual(t, env, map[string]string{
+		"DRONE_RUNNER_HOSTNAME": "runner-1",
+		"DRONE_BUILD_NUMBER":    "42",
+	})
+}
+
+func TestElapsed(t *testing.T) {
+	assert.Equal(t, elapsed(0, 0), time.Duration(0))
+	assert.Equal(t, elapsed(100, 160), time.Minute)
+	assert.Equal(t, elapsed(160, 100), time.Duration(0))
+
+	// A running build is measured up to now
+	started := time.Now().Add(-time.Hour).Unix()
+	assert.Assert(t, elapsed(started, 0) >= time.Hour)
+}
+
+func TestSplitList(t *testing.T) {
+	assert.DeepEqual(t, splitList("test, lint,,build "), []string{"test", "lint", "build"})
+	assert.Assert(t, splitList("") == nil)
+}
+
+func TestFailedStepsTemplate(t *testing.T) {
+	var body struct {
+		Blocks []struct {
+			Fields []struct {
+				Text string `json:"text"`
+			} `json:"fields"`
+		} `json:"blocks"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Build.Status = "failure"
+	plugin.Build.FailedSteps = []string{"test", "lint"}
+	plugin.Build.FailedStages = []string{"backend"}
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomTemplate = "basic_fail_1"
+	assert.NilError(t, plugin.Exec())
+
+	var fields []string
+	for _, block := range body.Blocks {
+		for _, field := range block.Fields {
+			fields = append(fields, field.Text)
+		}
+	}
+	assert.Equal(t, strings.Join(fields, "\n"), strings.Join([]string{
+		"*Project*: hello-world",
+		"*Branch*: master",
+		"*Author*: octocat",
+		"*Failed steps*: test, lint",
+		"*Failed stages*: backend",
+	}, "\n"))
+}
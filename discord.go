@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TransportDiscord posts embeds to a Discord webhook.
+const TransportDiscord = "discord"
+
+// Discord embed limits.
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldLimit       = 25
+	discordFieldNameLimit   = 256
+	discordFieldValueLimit  = 1024
+)
+
+type (
+	// discordMessage is the body of a Discord webhook.
+	discordMessage struct {
+		Username  string         `json:"username,omitempty"`
+		AvatarURL string         `json:"avatar_url,omitempty"`
+		Content   string         `json:"content,omitempty"`
+		Embeds    []discordEmbed `json:"embeds,omitempty"`
+	}
+
+	discordEmbed struct {
+		Title       string         `json:"title,omitempty"`
+		Description string         `json:"description,omitempty"`
+		Color       int            `json:"color,omitempty"`
+		Fields      []discordField `json:"fields,omitempty"`
+		Image       *discordImage  `json:"image,omitempty"`
+	}
+
+	discordField struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Inline bool   `json:"inline"`
+	}
+
+	discordImage struct {
+		URL string `json:"url"`
+	}
+)
+
+// discordTransport renders notifications as a single embed. The first
+// Block Kit header is the embed title and section fields are inline fields.
+type discordTransport struct {
+	webhook string
+	client  *http.Client
+	// Slack user IDs to Discord usernames
+	users map[string]string
+}
+
+func (t discordTransport) Send(n Notification) error {
+	return postJSON(t.client, t.webhook, t.payload(n))
+}
+
+func (t discordTransport) payload(n Notification) discordMessage {
+	title, blocks := splitHeader(n.Blocks)
+	text, fields := blocksToText(blocks)
+
+	var description []string
+	if n.Text != "" {
+		description = append(description, n.Text)
+	}
+	if text != "" {
+		description = append(description, text)
+	}
+
+	embed := discordEmbed{
+		Title:       truncate(t.text(title), discordTitleLimit),
+		Description: truncate(t.text(strings.Join(description, "\n")), discordDescriptionLimit),
+		Color:       colorInt(n.Color),
+	}
+	for _, field := range fields {
+		if len(embed.Fields) == discordFieldLimit {
+			break
+		}
+		name := field.Title
+		if name == "" {
+			// Discord rejects fields without a name
+			name = "\u200b"
+		}
+		embed.Fields = append(embed.Fields, discordField{
+			Name:   truncate(t.text(name), discordFieldNameLimit),
+			Value:  truncate(t.text(field.Value), discordFieldValueLimit),
+			Inline: true,
+		})
+	}
+	if n.ImageURL != "" {
+		embed.Image = &discordImage{URL: n.ImageURL}
+	}
+
+	return discordMessage{
+		Username:  n.Username,
+		AvatarURL: n.IconURL,
+		Embeds:    []discordEmbed{embed},
+	}
+}
+
+// text converts mrkdwn to Discord Markdown, which has no emoji shortcodes in
+// webhook messages.
+func (t discordTransport) text(s string) string {
+	return replaceEmoji(slackToMarkdown(s, t.users))
+}
+
+// colorInt turns a Slack attachment color or hex color into the integer
+// Discord expects.
+func colorInt(color string) int {
+	n, err := strconv.ParseInt(strings.TrimPrefix(hexColor(color), "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExecDiscord(t *testing.T) {
+	var payload map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPost)
+		assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Build.Status = "failure"
+	plugin.Build.Link = "https://drone.example.com/octocat/hello-world/1"
+	plugin.Config.Webhook = server.URL
+	plugin.Config.Transport = TransportDiscord
+	plugin.Config.CustomTemplate = "basic_fail_1"
+	plugin.Config.Username = "drone"
+	plugin.Config.IconURL = "https://example.com/drone.png"
+
+	assert.NilError(t, plugin.Exec())
+
+	expected := map[string]interface{}{
+		"username":   "drone",
+		"avatar_url": "https://example.com/drone.png",
+		"embeds": []interface{}{
+			map[string]interface{}{
+				"title":       "Build Failed. 🔴",
+				"description": "[View Build](https://drone.example.com/octocat/hello-world/1)",
+				"color":       float64(0xa30200),
+				"fields": []interface{}{
+					map[string]interface{}{"name": "Project", "value": "hello-world", "inline": true},
+					map[string]interface{}{"name": "Branch", "value": "master", "inline": true},
+					map[string]interface{}{"name": "Author", "value": "octocat", "inline": true},
+				},
+			},
+		},
+	}
+	assert.DeepEqual(t, payload, expected)
+}
+
+func TestDiscordText(t *testing.T) {
+	transport := discordTransport{users: map[string]string{"U123": "octocat"}}
+
+	payload := transport.payload(Notification{
+		Text:  "<@U123>: *success* <http://ci/1|build 1> :tada:",
+		Color: "good",
+	})
+
+	assert.Equal(t, len(payload.Embeds), 1)
+	assert.Equal(t, payload.Embeds[0].Description, "@octocat: **success** [build 1](http://ci/1) 🎉")
+	assert.Equal(t, payload.Embeds[0].Color, 0x2eb886)
+}
+
+func TestColorInt(t *testing.T) {
+	assert.Equal(t, colorInt("danger"), 0xa30200)
+	assert.Equal(t, colorInt("#439FE0"), 0x439fe0)
+	assert.Equal(t, colorInt("purple"), 0)
+}
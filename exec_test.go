@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// getFakeSlackPlugin returns a test plugin posting through the fake Slack API.
+func getFakeSlackPlugin(fake *fakeSlack) Plugin {
+	plugin := getTestPlugin()
+	plugin.Config.AccessToken = "test-access-token"
+	plugin.Config.APIURL = fake.URL
+	plugin.Config.Channel = "CBUILDS"
+	plugin.Config.MetadataEventType = DefaultMetadataEventType
+	return plugin
+}
+
+func TestExecAccessToken(t *testing.T) {
+	fake := newFakeSlack(t)
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.OutputFile = filepath.Join(t.TempDir(), "report.json")
+
+	assert.NilError(t, plugin.Exec())
+
+	posts := fake.calls("chat.postMessage")
+	assert.Equal(t, len(posts), 1)
+	assert.Equal(t, posts[0].Get("channel"), "#CBUILDS")
+	assert.Equal(t, posts[0].Get("text"), "Message Template:\nInitial commit\n\nMessage body\nInitial commit\nMessage body")
+
+	var metadata struct {
+		EventType    string                 `json:"event_type"`
+		EventPayload map[string]interface{} `json:"event_payload"`
+	}
+	assert.NilError(t, json.Unmarshal([]byte(posts[0].Get("metadata")), &metadata))
+	assert.Equal(t, metadata.EventType, DefaultMetadataEventType)
+	assert.Equal(t, metadata.EventPayload["repo"], "octocat/hello-world")
+
+	report := readTestReport(t, plugin.Config.OutputFile)
+	assert.Equal(t, report.Mode, modeMessage)
+	assert.Equal(t, len(report.Messages), 1)
+	assert.Equal(t, report.Messages[0].Channel, "CBUILDS")
+	assert.Equal(t, report.Messages[0].Timestamp, "1700000000.000001")
+	assert.Equal(t, report.Messages[0].Permalink, "https://fake.slack.com/archives/CBUILDS/p1700000000000001")
+}
+
+func TestExecAccessTokenError(t *testing.T) {
+	fake := newFakeSlack(t)
+	fake.Errors["chat.postMessage"] = "channel_not_found"
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.ErrorPolicy = ErrorPolicyFail
+
+	assert.ErrorContains(t, plugin.Exec(), "channel_not_found")
+
+	// A message that wasn't posted fails the build by default
+	plugin.Config.ErrorPolicy = ""
+	assert.ErrorContains(t, plugin.Exec(), "channel_not_found")
+
+	plugin.Config.ErrorPolicy = ErrorPolicyWarn
+	assert.NilError(t, plugin.Exec())
+}
+
+func TestExecCommitterDirectMessages(t *testing.T) {
+	fake := newFakeSlack(t)
+	fake.Users["first@example.com"] = "U1"
+	fake.Users["second@example.com"] = "U2"
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.CommitterSlackId = true
+	plugin.Config.CommitterListGitPath = newTestGitRepo(t, "first@example.com", "second@example.com")
+
+	assert.NilError(t, plugin.Exec())
+
+	opens := fake.calls("conversations.open")
+	assert.Equal(t, len(opens), 1)
+	assert.Equal(t, opens[0].Get("users"), "U2")
+
+	posts := fake.calls("chat.postMessage")
+	assert.Equal(t, len(posts), 2)
+	assert.Equal(t, posts[0].Get("channel"), "#CBUILDS")
+	assert.Equal(t, posts[1].Get("channel"), "DU2")
+}
+
+func TestExecCommitterEphemeral(t *testing.T) {
+	testCases := map[string]struct {
+		EphemeralError string
+		ErrorPolicy    ErrorPolicy
+		Expect         string
+		DirectMessages int
+	}{
+		"Delivered": {},
+		"Not In Channel": {
+			EphemeralError: "user_not_in_channel",
+			DirectMessages: 1,
+		},
+		"Other Error": {
+			EphemeralError: "channel_not_found",
+			ErrorPolicy:    ErrorPolicyFail,
+			Expect:         "channel_not_found",
+		},
+	}
+
+	for name, testCase := range testCases {
+		fake := newFakeSlack(t)
+		fake.Users["first@example.com"] = "U1"
+		fake.Users["second@example.com"] = "U2"
+		if testCase.EphemeralError != "" {
+			fake.Errors["chat.postEphemeral"] = testCase.EphemeralError
+		}
+
+		plugin := getFakeSlackPlugin(fake)
+		plugin.Config.CommitterSlackId = true
+		plugin.Config.CommitterDelivery = CommitterDeliveryEphemeral
+		plugin.Config.CommitterListGitPath = newTestGitRepo(t, "first@example.com", "second@example.com")
+		plugin.Config.ErrorPolicy = testCase.ErrorPolicy
+
+		err := plugin.Exec()
+		if testCase.Expect != "" {
+			assert.ErrorContains(t, err, testCase.Expect, name)
+		} else {
+			assert.NilError(t, err, name)
+		}
+
+		ephemerals := fake.calls("chat.postEphemeral")
+		assert.Equal(t, len(ephemerals), 1, name)
+		assert.Equal(t, ephemerals[0].Get("channel"), "#CBUILDS", name)
+		assert.Equal(t, ephemerals[0].Get("user"), "U2", name)
+		assert.Equal(t, ephemerals[0].Get("metadata"), "", name)
+
+		// The direct message of the fallback carries the metadata
+		assert.Equal(t, len(fake.calls("conversations.open")), testCase.DirectMessages, name)
+		posts := fake.calls("chat.postMessage")
+		assert.Equal(t, len(posts), 1+testCase.DirectMessages, name)
+		if testCase.DirectMessages > 0 {
+			assert.Equal(t, posts[1].Get("channel"), "DU2", name)
+			assert.Assert(t, posts[1].Get("metadata") != "", name)
+		}
+	}
+}
+
+func TestExecCommitterEphemeralFallback(t *testing.T) {
+	fake := newFakeSlack(t)
+	fake.Users["first@example.com"] = "U1"
+	fake.Users["second@example.com"] = "U2"
+	fake.Errors["chat.postEphemeral"] = "user_not_in_channel"
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.CommitterSlackId = true
+	plugin.Config.CommitterDelivery = CommitterDeliveryEphemeral
+	plugin.Config.CommitterListGitPath = newTestGitRepo(t, "first@example.com", "second@example.com")
+
+	assert.NilError(t, plugin.Exec())
+
+	ephemerals := fake.calls("chat.postEphemeral")
+	assert.Equal(t, len(ephemerals), 1)
+	assert.Equal(t, ephemerals[0].Get("user"), "U2")
+	assert.Equal(t, len(fake.calls("conversations.open")), 1)
+}
+
+func TestExecCommitterLookup(t *testing.T) {
+	fake := newFakeSlack(t)
+	fake.Users["second@example.com"] = "U2"
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.Channel = ""
+	plugin.Config.CommitterSlackId = true
+	plugin.Config.CommitterListGitPath = newTestGitRepo(t, "first@example.com", "second@example.com")
+	output := setTestOutput(t)
+
+	assert.NilError(t, plugin.Exec())
+	assert.Assert(t, strings.Contains(readTestOutput(t, output), "COMMITTERS_SLACK_IDS=U2\n"))
+	assert.DeepEqual(t, fake.methods(), []string{"users.lookupByEmail"})
+}
+
+func TestExecEmailLookup(t *testing.T) {
+	fake := newFakeSlack(t)
+	fake.Users["octocat@github.com"] = "U12345"
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.SlackIdOf = "octocat@github.com"
+	output := setTestOutput(t)
+
+	assert.NilError(t, plugin.Exec())
+	assert.Assert(t, strings.Contains(readTestOutput(t, output), "SLACK_ID_FROM_EMAIL=U12345\n"))
+	assert.DeepEqual(t, fake.methods(), []string{"users.lookupByEmail"})
+}
+
+func TestExecScheduleAndCancel(t *testing.T) {
+	fake := newFakeSlack(t)
+	output := setTestOutput(t)
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.SendAt = "1h"
+	assert.NilError(t, plugin.Exec())
+
+	schedules := fake.calls("chat.scheduleMessage")
+	assert.Equal(t, len(schedules), 1)
+	assert.Equal(t, schedules[0].Get("channel"), "#CBUILDS")
+	assert.Assert(t, strings.Contains(readTestOutput(t, output), "SCHEDULED_MESSAGE_ID=QFAKE\n"))
+
+	assert.Assert(t, strings.Contains(readTestOutput(t, output), "SCHEDULED_MESSAGE_CHANNEL=CBUILDS\n"))
+
+	// Cancelling needs the channel ID the message was scheduled in
+	plugin = getFakeSlackPlugin(fake)
+	plugin.Config.Channel = "builds"
+	plugin.Config.CancelScheduledID = "QFAKE"
+	plugin.Config.ErrorPolicy = ErrorPolicyFail
+	assert.ErrorContains(t, plugin.Exec(), `needs the channel ID from SCHEDULED_MESSAGE_CHANNEL, not "builds"`)
+	assert.Equal(t, len(fake.calls("chat.deleteScheduledMessage")), 0)
+
+	plugin.Config.ScheduledChannel = "CBUILDS"
+	assert.NilError(t, plugin.Exec())
+
+	deletes := fake.calls("chat.deleteScheduledMessage")
+	assert.Equal(t, len(deletes), 1)
+	assert.Equal(t, deletes[0].Get("channel"), "CBUILDS")
+	assert.Equal(t, deletes[0].Get("scheduled_message_id"), "QFAKE")
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 0)
+}
+
+func TestExecScheduleWebhook(t *testing.T) {
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = "http://127.0.0.1:0"
+	plugin.Config.SendAt = "1h"
+	assert.ErrorContains(t, plugin.Exec(), "messages can't be scheduled through a webhook")
+}
+
+func TestExecReactions(t *testing.T) {
+	fake := newFakeSlack(t)
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.Reactions = true
+	plugin.Config.MessageTs = "1700000000.000001"
+	assert.NilError(t, plugin.Exec())
+
+	adds := fake.calls("reactions.add")
+	assert.Equal(t, len(adds), 1)
+	assert.Equal(t, adds[0].Get("name"), "white_check_mark")
+	assert.Equal(t, adds[0].Get("timestamp"), "1700000000.000001")
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 0)
+
+	// Reactions address the message by channel ID
+	plugin.Config.Channel = "#builds"
+	plugin.Config.ErrorPolicy = ErrorPolicyFail
+	assert.ErrorContains(t, plugin.Exec(), "reactions need a channel ID")
+	assert.Equal(t, len(fake.calls("reactions.add")), 1)
+}
+
+func TestExecFindBuildMessage(t *testing.T) {
+	fake := newFakeSlack(t)
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Build.Status = "running"
+	assert.NilError(t, plugin.Exec())
+
+	plugin = getFakeSlackPlugin(fake)
+	plugin.Config.FindBuildMessage = FindBuildMessageUpdate
+	assert.NilError(t, plugin.Exec())
+
+	updates := fake.calls("chat.update")
+	assert.Equal(t, len(updates), 1)
+	assert.Equal(t, updates[0].Get("ts"), "1700000000.000001")
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 1)
+
+	plugin = getFakeSlackPlugin(fake)
+	plugin.Config.FindBuildMessage = FindBuildMessageThread
+	assert.NilError(t, plugin.Exec())
+
+	posts := fake.calls("chat.postMessage")
+	assert.Equal(t, len(posts), 2)
+	assert.Equal(t, posts[1].Get("thread_ts"), "1700000000.000001")
+
+	// PLUGIN_THREAD_BUILD is the thread mode
+	plugin = getFakeSlackPlugin(fake)
+	plugin.Config.ThreadBuild = true
+	assert.NilError(t, plugin.Exec())
+
+	posts = fake.calls("chat.postMessage")
+	assert.Equal(t, len(posts), 3)
+	assert.Equal(t, posts[2].Get("thread_ts"), "1700000000.000001")
+
+	// History can only be searched by channel ID
+	plugin = getFakeSlackPlugin(fake)
+	plugin.Config.FindBuildMessage = FindBuildMessageUpdate
+	plugin.Config.ErrorPolicy = ErrorPolicyFail
+	plugin.Config.Channel = "#builds"
+	assert.ErrorContains(t, plugin.Exec(), `searching the channel history needs a channel ID like C0123456789, not "#builds"`)
+
+	plugin.Config.Channel = "CBUILDS"
+	plugin.Config.Recipient = "octocat"
+	assert.ErrorContains(t, plugin.Exec(), "needs a channel, not a recipient")
+	assert.Equal(t, len(fake.calls("conversations.history")), 3)
+}
+
+func TestExecPinRelease(t *testing.T) {
+	fake := newFakeSlack(t)
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Build.Event = "tag"
+	plugin.Config.PinReleases = true
+	assert.NilError(t, plugin.Exec())
+
+	pins := fake.calls("pins.add")
+	assert.Equal(t, len(pins), 1)
+	assert.Equal(t, pins[0].Get("channel"), "CBUILDS")
+	assert.Equal(t, pins[0].Get("timestamp"), "1700000000.000001")
+}
+
+func TestExecNotifyOnChange(t *testing.T) {
+	fake := newFakeSlack(t)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	for number, status := range []string{"success", "success", "failure"} {
+		plugin := getFakeSlackPlugin(fake)
+		plugin.Build.Number = number + 1
+		plugin.Build.Status = status
+		plugin.Config.NotifyOnChange = true
+		plugin.Config.StateFile = stateFile
+		assert.NilError(t, plugin.Exec())
+	}
+
+	// The first build and the failure are posted
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 2)
+
+	// A fix that fails to post isn't recorded, so the next build retries it
+	for number := 4; number <= 5; number++ {
+		if number == 4 {
+			fake.Errors["chat.postMessage"] = "channel_not_found"
+		} else {
+			delete(fake.Errors, "chat.postMessage")
+		}
+		plugin := getFakeSlackPlugin(fake)
+		plugin.Build.Number = number
+		plugin.Config.NotifyOnChange = true
+		plugin.Config.StateFile = stateFile
+		if err := plugin.Exec(); number == 4 {
+			assert.ErrorContains(t, err, "channel_not_found")
+		} else {
+			assert.NilError(t, err)
+		}
+	}
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 4)
+
+	state, err := loadState(stateFile)
+	assert.NilError(t, err)
+	assert.Equal(t, state.Builds["octocat/hello-world@master"].Number, 5)
+	assert.Equal(t, state.Builds["octocat/hello-world@master"].Status, "success")
+}
+
+func TestExecSuppress(t *testing.T) {
+	fake := newFakeSlack(t)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	for number := 1; number <= 3; number++ {
+		plugin := getFakeSlackPlugin(fake)
+		plugin.Build.Number = number
+		plugin.Build.Status = "failure"
+		plugin.Config.SuppressWindow = time.Hour
+		plugin.Config.SuppressMode = SuppressThread
+		plugin.Config.StateFile = stateFile
+		assert.NilError(t, plugin.Exec())
+	}
+
+	posts := fake.calls("chat.postMessage")
+	assert.Equal(t, len(posts), 2)
+	assert.Equal(t, posts[1].Get("thread_ts"), "1700000000.000001")
+	assert.Equal(t, posts[1].Get("text"), "Failed 1 more time")
+
+	updates := fake.calls("chat.update")
+	assert.Equal(t, len(updates), 1)
+	assert.Equal(t, updates[0].Get("text"), "Failed 2 more times")
+}
+
+func TestExecSuppressFailedPost(t *testing.T) {
+	fake := newFakeSlack(t)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	// A notification that wasn't posted doesn't open a window
+	for number := 1; number <= 2; number++ {
+		if number == 1 {
+			fake.Errors["chat.postMessage"] = "channel_not_found"
+		} else {
+			delete(fake.Errors, "chat.postMessage")
+		}
+		plugin := getFakeSlackPlugin(fake)
+		plugin.Build.Number = number
+		plugin.Config.ErrorPolicy = ErrorPolicyWarn
+		plugin.Config.SuppressWindow = time.Hour
+		plugin.Config.StateFile = stateFile
+		assert.NilError(t, plugin.Exec())
+	}
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 2)
+}
+
+func TestExecSuppressHistoryChannelName(t *testing.T) {
+	fake := newFakeSlack(t)
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.Channel = "#builds"
+	plugin.Config.ErrorPolicy = ErrorPolicyFail
+	plugin.Config.SuppressWindow = time.Hour
+
+	assert.ErrorContains(t, plugin.Exec(), "needs a channel ID")
+	assert.Equal(t, len(fake.calls("conversations.history")), 0)
+}
+
+func TestExecQuietHours(t *testing.T) {
+	fake := newFakeSlack(t)
+	today := time.Now().UTC()
+	midnight := time.Date(today.Year(), today.Month(), today.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.QuietHolidays = today.Format(time.DateOnly)
+	plugin.Config.QuietAction = QuietDrop
+	assert.NilError(t, plugin.Exec())
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 0)
+
+	plugin.Config.QuietAction = QuietDefer
+	assert.NilError(t, plugin.Exec())
+
+	schedules := fake.calls("chat.scheduleMessage")
+	assert.Equal(t, len(schedules), 1)
+	assert.Equal(t, schedules[0].Get("post_at"), strconv.FormatInt(midnight.Unix(), 10))
+	assert.Equal(t, len(fake.calls("chat.postMessage")), 0)
+}
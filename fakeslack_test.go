@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Identity of the bot the fake Slack API authenticates.
+const (
+	fakeBotID  = "BFAKE"
+	fakeBotUID = "UFAKEBOT"
+	fakeFileID = "FFAKE"
+)
+
+type (
+	// fakeSlack is an in-process stand-in for the Slack Web API. It records
+	// every call and keeps the messages posted through it so history based
+	// features can find them again.
+	fakeSlack struct {
+		*httptest.Server
+		t *testing.T
+
+		mu       sync.Mutex
+		requests []fakeRequest
+		messages []map[string]interface{}
+		ts       int
+
+		// Users by email for users.lookupByEmail
+		Users map[string]string
+		// Error codes returned by API methods instead of a response
+		Errors map[string]string
+	}
+
+	// fakeRequest is a recorded API call.
+	fakeRequest struct {
+		Method string
+		Form   url.Values
+	}
+)
+
+// newFakeSlack starts a fake Slack API that is closed with the test.
+func newFakeSlack(t *testing.T) *fakeSlack {
+	t.Helper()
+
+	f := &fakeSlack{
+		t:      t,
+		Users:  map[string]string{},
+		Errors: map[string]string{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// calls returns the form of every call to method, in order.
+func (f *fakeSlack) calls(method string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var forms []url.Values
+	for _, r := range f.requests {
+		if r.Method == method {
+			forms = append(forms, r.Form)
+		}
+	}
+	return forms
+}
+
+// methods returns the distinct methods called, sorted.
+func (f *fakeSlack) methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	seen := map[string]bool{}
+	var methods []string
+	for _, r := range f.requests {
+		if !seen[r.Method] {
+			seen[r.Method] = true
+			methods = append(methods, r.Method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (f *fakeSlack) handle(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/")
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			f.t.Errorf("fake slack: could not parse %s: %v", method, err)
+		}
+	} else if err := r.ParseForm(); err != nil {
+		f.t.Errorf("fake slack: could not parse %s: %v", method, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, fakeRequest{Method: method, Form: r.Form})
+
+	if code, ok := f.Errors[method]; ok {
+		f.reply(w, map[string]interface{}{"ok": false, "error": code})
+		return
+	}
+
+	switch method {
+	case "auth.test":
+		f.reply(w, map[string]interface{}{"ok": true, "user_id": fakeBotUID, "bot_id": fakeBotID, "team": "fake"})
+	case "chat.postMessage":
+		channel := fakeChannelID(r.Form.Get("channel"))
+		ts := f.post(channel, r.Form)
+		f.reply(w, map[string]interface{}{"ok": true, "channel": channel, "ts": ts})
+	case "chat.postEphemeral":
+		f.reply(w, map[string]interface{}{"ok": true, "message_ts": f.nextTs()})
+	case "chat.update":
+		f.reply(w, map[string]interface{}{"ok": true, "channel": r.Form.Get("channel"), "ts": r.Form.Get("ts")})
+	case "chat.getPermalink":
+		f.reply(w, map[string]interface{}{
+			"ok":        true,
+			"channel":   r.Form.Get("channel"),
+			"permalink": "https://fake.slack.com/archives/" + r.Form.Get("channel") + "/p" + strings.ReplaceAll(r.Form.Get("message_ts"), ".", ""),
+		})
+	case "chat.scheduleMessage":
+		postAt, _ := strconv.ParseInt(r.Form.Get("post_at"), 10, 64)
+		f.reply(w, map[string]interface{}{
+			"ok":                   true,
+			"channel":              fakeChannelID(r.Form.Get("channel")),
+			"scheduled_message_id": "QFAKE",
+			"post_at":              postAt,
+		})
+	case "chat.deleteScheduledMessage", "reactions.add", "reactions.remove", "pins.add", "pins.remove":
+		f.reply(w, map[string]interface{}{"ok": true})
+	case "pins.list":
+		f.reply(w, map[string]interface{}{"ok": true, "items": []interface{}{}})
+	case "conversations.open":
+		f.reply(w, map[string]interface{}{"ok": true, "channel": map[string]interface{}{"id": "D" + r.Form.Get("users")}})
+	case "conversations.history":
+		f.reply(w, map[string]interface{}{"ok": true, "messages": f.history(r.Form.Get("channel")), "has_more": false})
+	case "users.lookupByEmail":
+		id, ok := f.Users[r.Form.Get("email")]
+		if !ok {
+			f.reply(w, map[string]interface{}{"ok": false, "error": "users_not_found"})
+			return
+		}
+		f.reply(w, map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": id}})
+	case "files.getUploadURLExternal":
+		f.reply(w, map[string]interface{}{"ok": true, "upload_url": f.URL + "/upload", "file_id": fakeFileID})
+	case "upload":
+		_, _ = w.Write([]byte("OK"))
+	case "files.completeUploadExternal":
+		var files []map[string]interface{}
+		if err := json.Unmarshal([]byte(r.Form.Get("files")), &files); err != nil {
+			f.t.Errorf("fake slack: invalid files: %v", err)
+		}
+		f.reply(w, map[string]interface{}{"ok": true, "files": files})
+	default:
+		f.t.Errorf("fake slack: unexpected call to %s", method)
+		f.reply(w, map[string]interface{}{"ok": false, "error": "unknown_method"})
+	}
+}
+
+func (f *fakeSlack) reply(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		f.t.Errorf("fake slack: could not encode response: %v", err)
+	}
+}
+
+func (f *fakeSlack) nextTs() string {
+	f.ts++
+	return fmt.Sprintf("1700000000.%06d", f.ts)
+}
+
+// post stores a message the way conversations.history returns it.
+func (f *fakeSlack) post(channel string, form url.Values) string {
+	ts := f.nextTs()
+	msg := map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+		"bot_id":  fakeBotID,
+		"text":    form.Get("text"),
+	}
+	if threadTs := form.Get("thread_ts"); threadTs != "" {
+		msg["thread_ts"] = threadTs
+	}
+	if metadata := form.Get("metadata"); metadata != "" {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(metadata), &m); err != nil {
+			f.t.Errorf("fake slack: invalid metadata: %v", err)
+		}
+		msg["metadata"] = m
+	}
+	f.messages = append(f.messages, msg)
+	return ts
+}
+
+// history returns the messages of channel, newest first.
+func (f *fakeSlack) history(channel string) []map[string]interface{} {
+	var messages []map[string]interface{}
+	for i := len(f.messages) - 1; i >= 0; i-- {
+		if f.messages[i]["channel"] == channel {
+			messages = append(messages, f.messages[i])
+		}
+	}
+	return messages
+}
+
+// fakeChannelID resolves "#name" or "name" to a stable channel ID and keeps
+// IDs as they are.
+func fakeChannelID(channel string) string {
+	channel = strings.TrimPrefix(channel, "#")
+	if channel != "" && channel == strings.ToUpper(channel) && strings.ContainsAny(channel[:1], "CDG") {
+		return channel
+	}
+	return "C" + strings.ToUpper(channel)
+}
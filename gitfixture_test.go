@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gotest.tools/v3/assert"
+)
+
+// gitFixture is a git repository for tests, built one commit at a time.
+// Commits are an hour apart from 2024-01-01, so logs have a stable order.
+type gitFixture struct {
+	t       *testing.T
+	Dir     string
+	repo    *git.Repository
+	commits int
+}
+
+// newGitFixture creates an empty repository in a temporary directory.
+func newGitFixture(t *testing.T) *gitFixture {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	assert.NilError(t, err)
+	return &gitFixture{t: t, Dir: dir, repo: repo}
+}
+
+// commit writes files, by path, and commits them as the author email.
+// Without files it changes file.txt, so every commit has changes. It returns
+// the hash of the commit.
+func (f *gitFixture) commit(author, message string, files map[string]string) string {
+	f.t.Helper()
+
+	if files == nil {
+		files = map[string]string{"file.txt": message + "\n" + strconv.Itoa(f.commits)}
+	}
+	for name, content := range files {
+		assert.NilError(f.t, os.MkdirAll(filepath.Join(f.Dir, filepath.Dir(name)), 0755))
+		assert.NilError(f.t, os.WriteFile(filepath.Join(f.Dir, name), []byte(content), 0644))
+	}
+
+	worktree, err := f.repo.Worktree()
+	assert.NilError(f.t, err)
+	assert.NilError(f.t, worktree.AddWithOptions(&git.AddOptions{All: true}))
+
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(f.commits) * time.Hour)
+	signature := &object.Signature{Name: author, Email: author, When: when}
+	hash, err := worktree.Commit(message, &git.CommitOptions{Author: signature, Committer: signature})
+	assert.NilError(f.t, err)
+	f.commits++
+	return hash.String()
+}
+
+// merge commits a merge of the commit into HEAD and returns its hash. The
+// tree is the one of HEAD.
+func (f *gitFixture) merge(author, message, hash string) string {
+	f.t.Helper()
+
+	head, err := f.repo.Head()
+	assert.NilError(f.t, err)
+	worktree, err := f.repo.Worktree()
+	assert.NilError(f.t, err)
+
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(f.commits) * time.Hour)
+	signature := &object.Signature{Name: author, Email: author, When: when}
+	merge, err := worktree.Commit(message, &git.CommitOptions{
+		Author:    signature,
+		Committer: signature,
+		Parents:   []plumbing.Hash{head.Hash(), plumbing.NewHash(hash)},
+	})
+	assert.NilError(f.t, err)
+	f.commits++
+	return merge.String()
+}
+
+// checkout checks out a branch, or detaches HEAD at a commit hash.
+func (f *gitFixture) checkout(ref string) {
+	f.t.Helper()
+
+	worktree, err := f.repo.Worktree()
+	assert.NilError(f.t, err)
+	options := &git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(ref)}
+	if plumbing.IsHash(ref) {
+		options = &git.CheckoutOptions{Hash: plumbing.NewHash(ref)}
+	}
+	assert.NilError(f.t, worktree.Checkout(options))
+}
+
+// tag tags HEAD with each name.
+func (f *gitFixture) tag(names ...string) {
+	f.t.Helper()
+
+	head, err := f.repo.Head()
+	assert.NilError(f.t, err)
+	for _, name := range names {
+		_, err := f.repo.CreateTag(name, head.Hash(), nil)
+		assert.NilError(f.t, err)
+	}
+}
+
+// newTestGitRepo creates a repository with one commit per author email.
+func newTestGitRepo(t *testing.T, emails ...string) string {
+	t.Helper()
+
+	fixture := newGitFixture(t)
+	for _, email := range emails {
+		fixture.commit(email, "commit by "+email, nil)
+	}
+	return fixture.Dir
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/slack-go/slack"
+)
+
+// TransportGoogleChat posts cardsV2 messages to a Google Chat webhook.
+const TransportGoogleChat = "googlechat"
+
+type (
+	// googleChatMessage is the body of a Google Chat incoming webhook.
+	googleChatMessage struct {
+		CardsV2 []googleChatCard `json:"cardsV2"`
+	}
+
+	googleChatCard struct {
+		CardID string         `json:"cardId"`
+		Card   googleChatBody `json:"card"`
+	}
+
+	googleChatBody struct {
+		Header   *googleChatHeader   `json:"header,omitempty"`
+		Sections []googleChatSection `json:"sections"`
+	}
+
+	googleChatHeader struct {
+		Title    string `json:"title"`
+		Subtitle string `json:"subtitle,omitempty"`
+	}
+
+	googleChatSection struct {
+		Widgets []googleChatWidget `json:"widgets"`
+	}
+
+	// googleChatWidget holds exactly one of its fields.
+	googleChatWidget struct {
+		TextParagraph *googleChatText       `json:"textParagraph,omitempty"`
+		DecoratedText *googleChatDecorated  `json:"decoratedText,omitempty"`
+		ButtonList    *googleChatButtonList `json:"buttonList,omitempty"`
+		Image         *googleChatImage      `json:"image,omitempty"`
+		Divider       *struct{}             `json:"divider,omitempty"`
+	}
+
+	googleChatText struct {
+		Text string `json:"text"`
+	}
+
+	googleChatDecorated struct {
+		TopLabel string `json:"topLabel,omitempty"`
+		Text     string `json:"text"`
+	}
+
+	googleChatButtonList struct {
+		Buttons []googleChatButton `json:"buttons"`
+	}
+
+	googleChatButton struct {
+		Text    string            `json:"text"`
+		OnClick googleChatOnClick `json:"onClick"`
+	}
+
+	googleChatOnClick struct {
+		OpenLink googleChatLink `json:"openLink"`
+	}
+
+	googleChatLink struct {
+		URL string `json:"url"`
+	}
+
+	googleChatImage struct {
+		ImageURL string `json:"imageUrl"`
+		AltText  string `json:"altText,omitempty"`
+	}
+)
+
+// googleChatTransport renders notifications as a card. The first Block Kit
+// header is the card header, section fields are decorated text and link
+// buttons a button list. Cards have no accent color, so the build color is
+// not shown.
+type googleChatTransport struct {
+	webhook string
+	client  *http.Client
+	// Slack user IDs to Google Chat names
+	users map[string]string
+}
+
+func (t googleChatTransport) Send(n Notification) error {
+	return postJSON(t.client, t.webhook, t.payload(n))
+}
+
+func (t googleChatTransport) payload(n Notification) googleChatMessage {
+	title, blocks := splitHeader(n.Blocks)
+
+	var card googleChatBody
+	if title != "" {
+		card.Header = &googleChatHeader{Title: replaceEmoji(convertMentions(unescapeSlack(title), t.users))}
+	}
+
+	section := googleChatSection{}
+	if n.Text != "" {
+		section.Widgets = append(section.Widgets, googleChatWidget{TextParagraph: &googleChatText{Text: t.text(n.Text)}})
+	}
+
+	for _, block := range blocks {
+		switch block := block.(type) {
+		case *slack.SectionBlock:
+			if block.Text != nil {
+				section.Widgets = append(section.Widgets, googleChatWidget{TextParagraph: &googleChatText{Text: t.text(block.Text.Text)}})
+			}
+			for _, field := range block.Fields {
+				f := splitField(field.Text)
+				section.Widgets = append(section.Widgets, googleChatWidget{DecoratedText: &googleChatDecorated{
+					TopLabel: replaceEmoji(convertMentions(unescapeSlack(f.Title), t.users)),
+					Text:     t.text(f.Value),
+				}})
+			}
+		case *slack.ContextBlock:
+			for _, element := range block.ContextElements.Elements {
+				if text, ok := element.(*slack.TextBlockObject); ok {
+					section.Widgets = append(section.Widgets, googleChatWidget{TextParagraph: &googleChatText{Text: "<i>" + t.text(text.Text) + "</i>"}})
+				}
+			}
+		case *slack.ImageBlock:
+			section.Widgets = append(section.Widgets, googleChatWidget{Image: &googleChatImage{ImageURL: block.ImageURL, AltText: block.AltText}})
+		case *slack.DividerBlock:
+			section.Widgets = append(section.Widgets, googleChatWidget{Divider: &struct{}{}})
+		case *slack.ActionBlock:
+			var buttons []googleChatButton
+			for _, element := range block.Elements.ElementSet {
+				if button, ok := element.(*slack.ButtonBlockElement); ok && button.URL != "" && button.Text != nil {
+					buttons = append(buttons, googleChatButton{
+						Text:    replaceEmoji(button.Text.Text),
+						OnClick: googleChatOnClick{OpenLink: googleChatLink{URL: button.URL}},
+					})
+				}
+			}
+			if len(buttons) > 0 {
+				section.Widgets = append(section.Widgets, googleChatWidget{ButtonList: &googleChatButtonList{Buttons: buttons}})
+			}
+		}
+	}
+
+	if n.ImageURL != "" {
+		section.Widgets = append(section.Widgets, googleChatWidget{Image: &googleChatImage{ImageURL: n.ImageURL}})
+	}
+	if len(section.Widgets) > 0 {
+		card.Sections = append(card.Sections, section)
+	}
+
+	return googleChatMessage{
+		CardsV2: []googleChatCard{{CardID: "build", Card: card}},
+	}
+}
+
+// text converts mrkdwn to the HTML subset of card text widgets.
+func (t googleChatTransport) text(s string) string {
+	return replaceEmoji(slackToHTML(s, t.users))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExecGoogleChat(t *testing.T) {
+	var payload map[string]interface{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPost)
+		assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"spaces/AAA/messages/BBB"}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Build.Link = "https://drone.example.com/octocat/hello-world/1"
+	plugin.Config.Webhook = server.URL
+	plugin.Config.Transport = TransportGoogleChat
+	plugin.Config.CustomTemplate = "basic_success_1"
+
+	assert.NilError(t, plugin.Exec())
+
+	cards := payload["cardsV2"].([]interface{})
+	assert.Equal(t, len(cards), 1)
+	card := cards[0].(map[string]interface{})
+	assert.Equal(t, card["cardId"], "build")
+
+	body := card["card"].(map[string]interface{})
+	assert.DeepEqual(t, body["header"], map[string]interface{}{"title": "Build Succeeded. ✅"})
+
+	sections := body["sections"].([]interface{})
+	assert.Equal(t, len(sections), 1)
+	widgets := sections[0].(map[string]interface{})["widgets"].([]interface{})
+	assert.DeepEqual(t, widgets, []interface{}{
+		map[string]interface{}{"decoratedText": map[string]interface{}{"topLabel": "Project", "text": "hello-world"}},
+		map[string]interface{}{"decoratedText": map[string]interface{}{"topLabel": "Branch", "text": "master"}},
+		map[string]interface{}{"decoratedText": map[string]interface{}{"topLabel": "Author", "text": "octocat"}},
+		map[string]interface{}{"buttonList": map[string]interface{}{"buttons": []interface{}{
+			map[string]interface{}{
+				"text":    "View Build",
+				"onClick": map[string]interface{}{"openLink": map[string]interface{}{"url": "https://drone.example.com/octocat/hello-world/1"}},
+			},
+		}}},
+	})
+}
+
+func TestGoogleChatText(t *testing.T) {
+	transport := googleChatTransport{}
+
+	payload := transport.payload(Notification{Text: "*failure* <http://ci/1?a=1&amp;b=2|build 1>\n_flaky_ 1 &lt; 2"})
+
+	card := payload.CardsV2[0].Card
+	assert.Assert(t, card.Header == nil)
+	assert.Equal(t, card.Sections[0].Widgets[0].TextParagraph.Text,
+		`<b>failure</b> <a href="http://ci/1?a=1&amp;b=2">build 1</a><br><i>flaky</i> 1 &lt; 2`)
+}
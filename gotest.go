@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults for go test reports.
+const (
+	DefaultGoTestLimit   = 5
+	DefaultCoverageLimit = 10
+)
+
+type (
+	// GoTestReport totals the results of go test -json output.
+	GoTestReport struct {
+		// Report files read
+		Files   int
+		Total   int
+		Passed  int
+		Failed  int
+		Skipped int
+		// Packages that ran tests
+		Packages int
+		// Packages that failed, including ones that didn't build
+		FailedPackages []string
+		// Failed tests, including the parents of failed subtests
+		Failures []GoTest
+		// Slowest top-level tests, up to the configured number
+		Slowest []GoTest
+
+		// Packages that ran tests and packages that failed, across reports
+		tested map[string]bool
+		failed map[string]bool
+	}
+
+	// GoTest is the result of a test.
+	GoTest struct {
+		Package string
+		Name    string
+		Seconds float64
+	}
+
+	// Coverage is the statement coverage of Go coverage profiles.
+	Coverage struct {
+		Statements int
+		Covered    int
+		Percent    float64
+		// Coverage per package, by package, up to the configured number
+		Packages []PackageCoverage
+		// Baseline is the coverage compared against, if HasBaseline
+		Baseline    float64
+		HasBaseline bool
+		// Delta is the change in percentage points since the baseline
+		Delta float64
+	}
+
+	// PackageCoverage is the statement coverage of a package.
+	PackageCoverage struct {
+		Package    string
+		Statements int
+		Covered    int
+		Percent    float64
+	}
+
+	// goTestEvent is a line of go test -json output.
+	goTestEvent struct {
+		Action  string
+		Package string
+		Test    string
+		Elapsed float64
+	}
+
+	// coverageBlock is a block of statements of a coverage profile.
+	coverageBlock struct {
+		file       string
+		statements int
+		covered    bool
+	}
+)
+
+// goTestReport reads the go test -json output matching the configured
+// globs. It returns nil when no file matches.
+func (p Plugin) goTestReport() (*GoTestReport, error) {
+	files, err := globFiles(droneWorkspace(), splitList(p.Config.GoTestReports))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		log.Printf("No go test reports match %s", p.Config.GoTestReports)
+		return nil, nil
+	}
+
+	report := &GoTestReport{tested: map[string]bool{}, failed: map[string]bool{}}
+	var tests []GoTest
+	for _, file := range files {
+		topLevel, err := report.read(file)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, topLevel...)
+	}
+
+	sort.SliceStable(tests, func(i, j int) bool {
+		return tests[i].Seconds > tests[j].Seconds
+	})
+	if limit := p.goTestLimit(); len(tests) > limit {
+		tests = tests[:limit]
+	}
+	report.Slowest = tests
+	return report, nil
+}
+
+func (p Plugin) goTestLimit() int {
+	if p.Config.GoTestLimit <= 0 {
+		return DefaultGoTestLimit
+	}
+	return p.Config.GoTestLimit
+}
+
+func (p Plugin) coverageLimit() int {
+	if p.Config.CoverageLimit <= 0 {
+		return DefaultCoverageLimit
+	}
+	return p.Config.CoverageLimit
+}
+
+// read adds the results of a go test -json file and returns its top-level
+// tests. Lines that aren't events, like build errors, are skipped.
+func (r *GoTestReport) read(file string) ([]GoTest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read go test report: %w", err)
+	}
+	defer f.Close()
+
+	var tests []GoTest
+	scanner := bufio.NewScanner(f)
+	// Output events can hold long lines
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var event goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Action != "pass" && event.Action != "fail" && event.Action != "skip" {
+			continue
+		}
+
+		if event.Test == "" {
+			if event.Action == "fail" && !r.failed[event.Package] {
+				r.failed[event.Package] = true
+				r.FailedPackages = append(r.FailedPackages, event.Package)
+			}
+			continue
+		}
+
+		if !r.tested[event.Package] {
+			r.tested[event.Package] = true
+			r.Packages++
+		}
+
+		test := GoTest{Package: event.Package, Name: event.Test, Seconds: event.Elapsed}
+		r.Total++
+		switch event.Action {
+		case "pass":
+			r.Passed++
+		case "fail":
+			r.Failed++
+			r.Failures = append(r.Failures, test)
+		case "skip":
+			r.Skipped++
+			continue
+		}
+		if !strings.Contains(test.Name, "/") {
+			tests = append(tests, test)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read go test report %s: %w", file, err)
+	}
+	r.Files++
+	return tests, nil
+}
+
+// Duration is how long the test took, like 1.52s.
+func (t GoTest) Duration() string {
+	return time.Duration(t.Seconds * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+// coverage reads the coverage profiles matching the configured globs and
+// compares them with the baseline, if any. It returns nil when no file
+// matches.
+func (p Plugin) coverage() (*Coverage, error) {
+	files, err := globFiles(droneWorkspace(), splitList(p.Config.CoverageProfiles))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		log.Printf("No coverage profiles match %s", p.Config.CoverageProfiles)
+		return nil, nil
+	}
+	coverage, err := readCoverageProfiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if limit := p.coverageLimit(); len(coverage.Packages) > limit {
+		coverage.Packages = coverage.Packages[:limit]
+	}
+
+	if p.Config.CoverageBaseline != "" {
+		baseline := p.Config.CoverageBaseline
+		if !filepath.IsAbs(baseline) {
+			baseline = filepath.Join(droneWorkspace(), baseline)
+		}
+		percent, err := readCoverageBaseline(baseline)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// The first build has nothing to compare with
+			log.Printf("No coverage baseline %s", baseline)
+		case err != nil:
+			return nil, err
+		default:
+			coverage.Baseline = percent
+			coverage.HasBaseline = true
+			coverage.Delta = coverage.Percent - percent
+		}
+	}
+	return coverage, nil
+}
+
+// readCoverageProfiles merges the profiles, so a block covered by any of
+// them is covered.
+func readCoverageProfiles(files []string) (*Coverage, error) {
+	blocks := map[string]*coverageBlock{}
+	for _, file := range files {
+		if err := readCoverageProfile(file, blocks); err != nil {
+			return nil, err
+		}
+	}
+
+	coverage := &Coverage{}
+	packages := map[string]*PackageCoverage{}
+	for _, block := range blocks {
+		name := path.Dir(block.file)
+		pkg, ok := packages[name]
+		if !ok {
+			pkg = &PackageCoverage{Package: name}
+			packages[name] = pkg
+		}
+		pkg.Statements += block.statements
+		coverage.Statements += block.statements
+		if block.covered {
+			pkg.Covered += block.statements
+			coverage.Covered += block.statements
+		}
+	}
+	coverage.Percent = percent(coverage.Covered, coverage.Statements)
+	for _, pkg := range packages {
+		pkg.Percent = percent(pkg.Covered, pkg.Statements)
+		coverage.Packages = append(coverage.Packages, *pkg)
+	}
+	sort.Slice(coverage.Packages, func(i, j int) bool {
+		return coverage.Packages[i].Package < coverage.Packages[j].Package
+	})
+	return coverage, nil
+}
+
+// readCoverageProfile adds the blocks of a profile, with lines like
+// "example.com/pkg/file.go:10.2,12.16 2 1".
+func readCoverageProfile(file string, blocks map[string]*coverageBlock) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("could not read coverage profile: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if !strings.HasPrefix(lines[0], "mode:") {
+		return fmt.Errorf("invalid coverage profile %s: missing mode line", file)
+	}
+	for i, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		colon := strings.LastIndex(fields[0], ":")
+		if len(fields) != 3 || colon < 0 {
+			return fmt.Errorf("invalid coverage profile %s: line %d: %q", file, i+2, line)
+		}
+		statements, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("invalid coverage profile %s: line %d: %w", file, i+2, err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("invalid coverage profile %s: line %d: %w", file, i+2, err)
+		}
+
+		block, ok := blocks[fields[0]]
+		if !ok {
+			block = &coverageBlock{file: fields[0][:colon], statements: statements}
+			blocks[fields[0]] = block
+		}
+		block.covered = block.covered || count > 0
+	}
+	return nil
+}
+
+// readCoverageBaseline reads a percentage, like "81.5%", or a coverage
+// profile.
+func readCoverageBaseline(file string) (float64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, fmt.Errorf("could not read coverage baseline: %w", err)
+	}
+	if percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(string(data)), "%"), 64); err == nil {
+		return percent, nil
+	}
+	coverage, err := readCoverageProfiles([]string{file})
+	if err != nil {
+		return 0, err
+	}
+	return coverage.Percent, nil
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// Percentage is the coverage like 81.5%.
+func (c Coverage) Percentage() string {
+	return fmt.Sprintf("%.1f%%", c.Percent)
+}
+
+// Change is the delta like +1.2% or -0.4%.
+func (c Coverage) Change() string {
+	// Rounding must not turn a tiny drop into -0.0%
+	if math.Abs(c.Delta) < 0.05 {
+		return "+0.0%"
+	}
+	return fmt.Sprintf("%+.1f%%", c.Delta)
+}
+
+// Percentage is the coverage like 81.5%.
+func (c PackageCoverage) Percentage() string {
+	return fmt.Sprintf("%.1f%%", c.Percent)
+}
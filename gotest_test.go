@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func getGoTestPlugin(t *testing.T) Plugin {
+	t.Setenv("DRONE_WORKSPACE", "testdata/gotest")
+
+	plugin := getTestPlugin()
+	plugin.Config.GoTestReports = "report.json"
+	plugin.Config.CoverageProfiles = "coverage.out"
+	return plugin
+}
+
+func TestGoTestReport(t *testing.T) {
+	plugin := getGoTestPlugin(t)
+	plugin.Config.GoTestLimit = 2
+	report, err := plugin.goTestReport()
+	assert.NilError(t, err)
+
+	assert.Equal(t, report.Files, 1)
+	assert.Equal(t, report.Total, 7)
+	assert.Equal(t, report.Passed, 4)
+	assert.Equal(t, report.Failed, 2)
+	assert.Equal(t, report.Skipped, 1)
+	// Packages without tests and ones that didn't build ran no tests
+	assert.Equal(t, report.Packages, 2)
+	// Build failures only fail the package
+	assert.DeepEqual(t, report.FailedPackages, []string{
+		"github.com/octocat/hello-world/api",
+		"github.com/octocat/hello-world/billing",
+	})
+	assert.DeepEqual(t, report.Failures, []GoTest{
+		{Package: "github.com/octocat/hello-world/api", Name: "TestHandle/empty_page", Seconds: 0.01},
+		{Package: "github.com/octocat/hello-world/api", Name: "TestHandle", Seconds: 0.02},
+	})
+	assert.DeepEqual(t, report.Slowest, []GoTest{
+		{Package: "github.com/octocat/hello-world/auth", Name: "TestLogin", Seconds: 1.5},
+		{Package: "github.com/octocat/hello-world/auth", Name: "TestLogout", Seconds: 0.3},
+	})
+	assert.Equal(t, report.Slowest[0].Duration(), "1.5s")
+
+	plugin.Config.GoTestReports = "missing.json"
+	report, err = plugin.goTestReport()
+	assert.NilError(t, err)
+	assert.Assert(t, report == nil)
+}
+
+func TestCoverage(t *testing.T) {
+	plugin := getGoTestPlugin(t)
+	coverage, err := plugin.coverage()
+	assert.NilError(t, err)
+
+	assert.Equal(t, coverage.Statements, 15)
+	assert.Equal(t, coverage.Covered, 9)
+	assert.Equal(t, coverage.Percentage(), "60.0%")
+	assert.Assert(t, !coverage.HasBaseline)
+	assert.Equal(t, len(coverage.Packages), 2)
+	assert.Equal(t, coverage.Packages[0].Package, "github.com/octocat/hello-world/api")
+	assert.Equal(t, coverage.Packages[0].Percentage(), "77.8%")
+	assert.Equal(t, coverage.Packages[1].Percentage(), "33.3%")
+
+	plugin.Config.CoverageLimit = 1
+	coverage, err = plugin.coverage()
+	assert.NilError(t, err)
+	assert.Equal(t, len(coverage.Packages), 1)
+	assert.Equal(t, coverage.Percentage(), "60.0%")
+	plugin.Config.CoverageLimit = 0
+
+	// Blocks covered by any profile are covered
+	plugin.Config.CoverageProfiles = "coverage*.out"
+	coverage, err = plugin.coverage()
+	assert.NilError(t, err)
+	assert.Equal(t, coverage.Percentage(), "86.7%")
+	assert.Equal(t, coverage.Packages[1].Percentage(), "100.0%")
+}
+
+func TestCoverageBaseline(t *testing.T) {
+	plugin := getGoTestPlugin(t)
+
+	plugin.Config.CoverageBaseline = "baseline.out"
+	coverage, err := plugin.coverage()
+	assert.NilError(t, err)
+	assert.Assert(t, coverage.HasBaseline)
+	assert.Equal(t, coverage.Change(), "+15.6%")
+
+	plugin.Config.CoverageBaseline = "baseline.txt"
+	coverage, err = plugin.coverage()
+	assert.NilError(t, err)
+	assert.Equal(t, coverage.Baseline, 62.5)
+	assert.Equal(t, coverage.Change(), "-2.5%")
+
+	// The first build has no baseline yet
+	plugin.Config.CoverageBaseline = "missing.out"
+	coverage, err = plugin.coverage()
+	assert.NilError(t, err)
+	assert.Assert(t, !coverage.HasBaseline)
+
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "broken.out"), []byte("mode: set\nbroken\n"), 0644))
+	plugin.Config.CoverageBaseline = filepath.Join(dir, "broken.out")
+	_, err = plugin.coverage()
+	assert.ErrorContains(t, err, "invalid coverage profile")
+}
+
+func TestTestReportTemplate(t *testing.T) {
+	var body struct {
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+			Fields []struct {
+				Text string `json:"text"`
+			} `json:"fields"`
+			Elements []map[string]interface{} `json:"elements"`
+		} `json:"blocks"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body.Blocks = nil
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	plugin := getGoTestPlugin(t)
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomTemplate = "test_report_1"
+	plugin.Config.CoverageBaseline = "baseline.txt"
+	plugin.Config.GoTestLimit = 1
+	assert.NilError(t, plugin.Exec())
+
+	var types []string
+	for _, block := range body.Blocks {
+		types = append(types, block.Type)
+	}
+	assert.DeepEqual(t, types, []string{"header", "section", "section", "section", "context", "actions"})
+	assert.Equal(t, body.Blocks[0].Text.Text, "hello-world tests failed :x:")
+	assert.Equal(t, body.Blocks[1].Fields[1].Text, "*Tests*: 4 passed, 2 failed, 1 skipped")
+	assert.Equal(t, body.Blocks[1].Fields[2].Text, "*Coverage*: 60.0% (-2.5%)")
+	assert.Equal(t, body.Blocks[2].Text.Text, "*Failing packages*\n• `github.com/octocat/hello-world/api`\n• `github.com/octocat/hello-world/billing`")
+	assert.Equal(t, body.Blocks[3].Text.Text, "*Slowest tests*\n• `TestLogin` 1.5s")
+	assert.Equal(t, body.Blocks[4].Elements[0]["text"], "`github.com/octocat/hello-world/api` 77.8% · `github.com/octocat/hello-world/auth` 33.3%")
+
+	// Without reports the template still renders
+	plugin.Config.GoTestReports = ""
+	plugin.Config.CoverageProfiles = ""
+	assert.NilError(t, plugin.Exec())
+	assert.Equal(t, body.Blocks[0].Text.Text, "hello-world test report")
+	assert.Equal(t, len(body.Blocks), 3)
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// Defaults for test reports.
+const DefaultJUnitLimit = 5
+
+// maxFailureMessage is the longest failure message shown for a test.
+const maxFailureMessage = 200
+
+type (
+	// TestReport totals the test results of a build.
+	TestReport struct {
+		// Report files read
+		Files   int
+		Total   int
+		Passed  int
+		Failed  int
+		Skipped int
+		// Seconds the tests took, as reported
+		Seconds float64
+		// Failed tests in report order
+		Failures []TestFailure
+		// Text is the report in mrkdwn, with up to the configured number of
+		// failures
+		Text string
+	}
+
+	// TestFailure is a failed test of a report.
+	TestFailure struct {
+		Suite   string
+		Class   string
+		Name    string
+		Type    string
+		Message string
+	}
+
+	// junitSuite is a testsuite or testsuites element, which nest.
+	junitSuite struct {
+		Name   string       `xml:"name,attr"`
+		Time   string       `xml:"time,attr"`
+		Suites []junitSuite `xml:"testsuite"`
+		Cases  []junitCase  `xml:"testcase"`
+	}
+
+	junitCase struct {
+		Name      string       `xml:"name,attr"`
+		Classname string       `xml:"classname,attr"`
+		Time      string       `xml:"time,attr"`
+		Failure   *junitResult `xml:"failure"`
+		Error     *junitResult `xml:"error"`
+		Skipped   *junitResult `xml:"skipped"`
+	}
+
+	junitResult struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// testReport reads the JUnit XML reports matching the configured globs.
+// It returns nil when no file matches.
+func (p Plugin) testReport() (*TestReport, error) {
+	files, err := globFiles(droneWorkspace(), splitList(p.Config.JUnitReports))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		log.Printf("No test reports match %s", p.Config.JUnitReports)
+		return nil, nil
+	}
+
+	report := &TestReport{}
+	for _, file := range files {
+		if err := report.read(file); err != nil {
+			return nil, err
+		}
+	}
+	report.Text = report.mrkdwn(p.junitLimit())
+	return report, nil
+}
+
+func (p Plugin) junitLimit() int {
+	if p.Config.JUnitLimit <= 0 {
+		return DefaultJUnitLimit
+	}
+	return p.Config.JUnitLimit
+}
+
+// globFiles returns the files matching any of the patterns, in pattern
+// order and without duplicates. Relative patterns are resolved in root, and
+// one ** matches any number of directories.
+func globFiles(root string, patterns []string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(root, pattern)
+		}
+		matches, err := glob(filepath.ToSlash(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid report pattern %q: %w", pattern, err)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() || seen[match] {
+				continue
+			}
+			seen[match] = true
+			files = append(files, match)
+		}
+	}
+	return files, nil
+}
+
+func glob(pattern string) ([]string, error) {
+	base, rest, ok := strings.Cut(pattern, "**")
+	if !ok {
+		return filepath.Glob(filepath.FromSlash(pattern))
+	}
+	rest = strings.TrimPrefix(rest, "/")
+	if _, err := path.Match(rest, ""); err != nil {
+		return nil, err
+	}
+	if base = strings.TrimSuffix(base, "/"); base == "" {
+		base = "/"
+	}
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(base), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if rest == "" {
+			matches = append(matches, name)
+			return nil
+		}
+		// Match the pattern against as many trailing path elements
+		elems := strings.Split(filepath.ToSlash(name), "/")
+		n := strings.Count(rest, "/") + 1
+		if len(elems) < n {
+			return nil
+		}
+		if ok, _ := path.Match(rest, strings.Join(elems[len(elems)-n:], "/")); ok {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// read adds the results of a JUnit XML file. The root can be a testsuites
+// or a testsuite element, as Maven writes them.
+func (r *TestReport) read(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("could not read test report: %w", err)
+	}
+	var suite junitSuite
+	if err := xml.Unmarshal(data, &suite); err != nil {
+		return fmt.Errorf("could not parse test report %s: %w", file, err)
+	}
+	r.Files++
+	r.Seconds += r.add(suite)
+	return nil
+}
+
+// add counts the tests of a suite and returns its time, which is summed
+// from its children when the suite has none.
+func (r *TestReport) add(suite junitSuite) float64 {
+	var seconds float64
+	for _, child := range suite.Suites {
+		seconds += r.add(child)
+	}
+	for _, c := range suite.Cases {
+		seconds += parseSeconds(c.Time)
+		r.Total++
+		switch {
+		case c.Failure != nil:
+			r.Failed++
+			r.Failures = append(r.Failures, newTestFailure(suite.Name, c, c.Failure))
+		case c.Error != nil:
+			r.Failed++
+			r.Failures = append(r.Failures, newTestFailure(suite.Name, c, c.Error))
+		case c.Skipped != nil:
+			r.Skipped++
+		default:
+			r.Passed++
+		}
+	}
+	if suite.Time != "" {
+		return parseSeconds(suite.Time)
+	}
+	return seconds
+}
+
+// parseSeconds reads a time attribute, which Maven writes with thousands
+// separators.
+func parseSeconds(s string) float64 {
+	seconds, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return seconds
+}
+
+// newTestFailure uses the message of the result, or its text when the
+// message is missing or a placeholder the text doesn't repeat.
+func newTestFailure(suite string, c junitCase, result *junitResult) TestFailure {
+	message := strings.TrimSpace(result.Message)
+	if text := strings.TrimSpace(result.Text); message == "" || (text != "" && !strings.Contains(text, message)) {
+		message = text
+	}
+	return TestFailure{
+		Suite:   suite,
+		Class:   c.Classname,
+		Name:    c.Name,
+		Type:    result.Type,
+		Message: truncateMessage(message, maxFailureMessage),
+	}
+}
+
+// truncateMessage trims the lines of s, dropping blank ones, and cuts it
+// to max characters.
+func truncateMessage(s string, max int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	s = strings.Join(lines, "\n")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
+
+// Title is the name of the test, with its class unless the name repeats it.
+func (f TestFailure) Title() string {
+	if f.Class == "" || strings.HasPrefix(f.Name, f.Class) {
+		return f.Name
+	}
+	return f.Class + "." + f.Name
+}
+
+// Duration is how long the tests took, like 1.52s.
+func (r *TestReport) Duration() string {
+	return time.Duration(r.Seconds * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+// mrkdwn renders the totals and up to limit failures.
+func (r *TestReport) mrkdwn(limit int) string {
+	text := fmt.Sprintf("*Tests:* %d passed, %d failed, %d skipped in %s", r.Passed, r.Failed, r.Skipped, r.Duration())
+	if failures := r.failuresMrkdwn(limit, maxSectionText-len(text)-1); failures != "" {
+		text += "\n" + failures
+	}
+	return text
+}
+
+// failuresMrkdwn renders up to limit failures as a code block, in at most
+// size bytes, and notes how many were left out.
+func (r *TestReport) failuresMrkdwn(limit, size int) string {
+	if len(r.Failures) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("```")
+	shown := 0
+	for i, failure := range r.Failures {
+		if i == limit {
+			break
+		}
+		entry := escapeMrkdwn(failure.Title())
+		if failure.Message != "" {
+			entry += "\n  " + strings.ReplaceAll(escapeMrkdwn(failure.Message), "\n", "\n  ")
+		}
+		if shown > 0 {
+			entry = "\n" + entry
+		}
+		// Leave room for the end of the block and the "and N more" line
+		if b.Len()+len(entry) > size-32 {
+			break
+		}
+		b.WriteString(strings.ReplaceAll(entry, "```", "'''"))
+		shown++
+	}
+	b.WriteString("```")
+	if shown == 0 {
+		b.Reset()
+	}
+	if more := len(r.Failures) - shown; more > 0 {
+		if shown > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "_and %d more_", more)
+	}
+	return b.String()
+}
+
+// blocks is the report as a section of totals followed by a section with
+// up to limit failures, if any.
+func (r *TestReport) blocks(limit int) []slack.Block {
+	fields := []*slack.TextBlockObject{
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Passed*\n%d", r.Passed), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Failed*\n%d", r.Failed), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Skipped*\n%d", r.Skipped), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Time*\n%s", r.Duration()), false, false),
+	}
+	blocks := []slack.Block{slack.NewSectionBlock(nil, fields, nil)}
+	if failures := r.failuresMrkdwn(limit, maxSectionText); failures != "" {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, failures, false, false), nil, nil))
+	}
+	return blocks
+}
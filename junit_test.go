@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func getJUnitPlugin(t *testing.T, reports string) Plugin {
+	t.Setenv("DRONE_WORKSPACE", "testdata/junit")
+
+	plugin := getTestPlugin()
+	plugin.Config.JUnitReports = reports
+	return plugin
+}
+
+func TestTestReport(t *testing.T) {
+	plugin := getJUnitPlugin(t, "*.xml, maven/TEST-*.xml")
+	report, err := plugin.testReport()
+	assert.NilError(t, err)
+
+	assert.Equal(t, report.Files, 3)
+	assert.Equal(t, report.Total, 11)
+	assert.Equal(t, report.Passed, 5)
+	assert.Equal(t, report.Failed, 4)
+	assert.Equal(t, report.Skipped, 2)
+	// Go reports have no total, Maven writes thousands separators
+	assert.Equal(t, report.Duration(), "20m6.035s")
+
+	assert.DeepEqual(t, report.Failures, []TestFailure{
+		{
+			Suite:   "github.com/octocat/hello-world/api",
+			Class:   "github.com/octocat/hello-world/api",
+			Name:    "TestHandle",
+			Message: "handlers_test.go:42: got status 500, want 200\nhandlers_test.go:43: body: internal error",
+		},
+		{
+			Suite:   "Button",
+			Class:   "Button calls onClick when clicked",
+			Name:    "Button calls onClick when clicked",
+			Message: "Error: expect(jest.fn()).toHaveBeenCalledTimes(expected)\nExpected number of calls: 1\nReceived number of calls: 0\nat Object.<anonymous> (/drone/src/web/src/Button.test.tsx:18:25)",
+		},
+		{
+			Suite:   "com.example.AppTest",
+			Class:   "com.example.AppTest",
+			Name:    "testDivide",
+			Type:    "org.opentest4j.AssertionFailedError",
+			Message: "expected: <2> but was: <3>",
+		},
+		{
+			Suite:   "com.example.AppTest",
+			Class:   "com.example.AppTest",
+			Name:    "testLoad",
+			Type:    "java.lang.NullPointerException",
+			Message: `Cannot invoke "String.length()" because "name" is null`,
+		},
+	})
+	assert.Equal(t, report.Failures[0].Title(), "github.com/octocat/hello-world/api.TestHandle")
+	assert.Equal(t, report.Failures[1].Title(), "Button calls onClick when clicked")
+}
+
+func TestTestReportText(t *testing.T) {
+	plugin := getJUnitPlugin(t, "maven/*.xml")
+	plugin.Config.JUnitLimit = 1
+	report, err := plugin.testReport()
+	assert.NilError(t, err)
+
+	assert.Equal(t, report.Text, strings.Join([]string{
+		"*Tests:* 1 passed, 2 failed, 1 skipped in 20m4.5s",
+		"```com.example.AppTest.testDivide",
+		"  expected: &lt;2&gt; but was: &lt;3&gt;```",
+		"_and 1 more_",
+	}, "\n"))
+}
+
+func TestTestReportErrors(t *testing.T) {
+	// No reports isn't an error, the tests may not have run
+	plugin := getJUnitPlugin(t, "missing/*.xml")
+	report, err := plugin.testReport()
+	assert.NilError(t, err)
+	assert.Assert(t, report == nil)
+
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "broken.xml"), []byte("<testsuite><testcase>"), 0644))
+	plugin.Config.JUnitReports = filepath.Join(dir, "*.xml")
+	_, err = plugin.testReport()
+	assert.ErrorContains(t, err, "could not parse test report")
+}
+
+func TestGlobFiles(t *testing.T) {
+	files, err := globFiles("testdata/junit", []string{"**/*.xml", "go.xml"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, files, []string{
+		filepath.Join("testdata/junit", "go.xml"),
+		filepath.Join("testdata/junit", "jest.xml"),
+		filepath.Join("testdata/junit", "maven", "TEST-com.example.AppTest.xml"),
+	})
+
+	files, err = globFiles("testdata", []string{"junit/**/TEST-*.xml"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, files, []string{filepath.Join("testdata/junit", "maven", "TEST-com.example.AppTest.xml")})
+}
+
+func TestTruncateMessage(t *testing.T) {
+	assert.Equal(t, truncateMessage("  first\n\n\tsecond  \n", 20), "first\nsecond")
+	assert.Equal(t, truncateMessage(strings.Repeat("é", 10), 5), "éééé…")
+}
+
+func TestTestReportBlocks(t *testing.T) {
+	var body struct {
+		Attachments []struct {
+			Text string `json:"text"`
+		} `json:"attachments"`
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body.Attachments, body.Blocks = nil, nil
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	plugin := getJUnitPlugin(t, "**/*.xml")
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomTemplate = "basic_success_1"
+	plugin.Config.JUnitLimit = 2
+	assert.NilError(t, plugin.Exec())
+
+	// The totals and failures go before the buttons
+	assert.Equal(t, len(body.Blocks), 5)
+	totals, failures := string(body.Blocks[2]), string(body.Blocks[3])
+	assert.Assert(t, strings.Contains(totals, `"text":"*Failed*\n4"`), totals)
+	assert.Assert(t, strings.Contains(failures, "```github.com/octocat/hello-world/api.TestHandle"), failures)
+	assert.Assert(t, strings.Contains(failures, "_and 2 more_"), failures)
+
+	// Text messages get the report appended
+	plugin.Config.CustomTemplate = ""
+	assert.NilError(t, plugin.Exec())
+	text := body.Attachments[0].Text
+	assert.Assert(t, strings.Contains(text, "\n*Tests:* 5 passed, 4 failed, 2 skipped in 20m6.035s\n```"), text)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Modes of Config.FindBuildMessage.
+const (
+	FindBuildMessageUpdate = "update"
+	FindBuildMessageThread = "thread"
+)
+
+// DefaultLookBack bounds the channel history searched for the build message.
+const DefaultLookBack = 24 * time.Hour
+
+// historyPageSize is the number of messages read per conversations.history call.
+const historyPageSize = 100
+
+// buildMessageQuery identifies the message the plugin posted for a build.
+type buildMessageQuery struct {
+	Channel string
+	Repo    string
+	Number  int
+	// Link of the build, used for messages posted without metadata
+	Link string
+	// Only messages posted by this bot match when set
+	BotID string
+	// Messages older than this are not searched
+	Oldest time.Time
+}
+
+func (q buildMessageQuery) matches(msg slack.Message) bool {
+	if q.BotID != "" && msg.BotID != q.BotID {
+		return false
+	}
+
+	if msg.Metadata.EventPayload != nil {
+		return isBuildMessage(msg.Metadata, q.Repo, q.Number)
+	}
+
+	if q.Link == "" {
+		return false
+	}
+	if containsLink(msg.Text, q.Link) {
+		return true
+	}
+	for _, attachment := range msg.Attachments {
+		if containsLink(attachment.Fallback, q.Link) || containsLink(attachment.Text, q.Link) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsLink reports whether text contains link as a whole, so the link of
+// build 12 doesn't match the link of build 123.
+func containsLink(text, link string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], link)
+		if i < 0 {
+			return false
+		}
+		end := offset + i + len(link)
+		if end == len(text) || !isAlphanumeric(text[end]) {
+			return true
+		}
+		offset += i + 1
+	}
+}
+
+func isAlphanumeric(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// slackTimestamp formats t the way Slack formats message timestamps.
+func slackTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
+
+// scanHistory pages through the channel history, newest first, back to
+// oldest and calls visit for every message until it returns false.
+func scanHistory(api *slack.Client, channelID string, oldest time.Time, visit func(msg slack.Message) bool) error {
+	params := &slack.GetConversationHistoryParameters{
+		ChannelID:          channelID,
+		Limit:              historyPageSize,
+		IncludeAllMetadata: true,
+	}
+	if !oldest.IsZero() {
+		params.Oldest = slackTimestamp(oldest)
+	}
+
+	for {
+		history, err := api.GetConversationHistory(params)
+		if err != nil {
+			return fmt.Errorf("failed to read channel history: %w", err)
+		}
+
+		for _, msg := range history.Messages {
+			if !visit(msg) {
+				return nil
+			}
+		}
+
+		if !history.HasMore || history.ResponseMetaData.NextCursor == "" {
+			return nil
+		}
+		params.Cursor = history.ResponseMetaData.NextCursor
+	}
+}
+
+// findBuildMessage returns the oldest message matching q, or nil.
+func findBuildMessage(api *slack.Client, q buildMessageQuery) (*slack.Message, error) {
+	var found *slack.Message
+	err := scanHistory(api, q.Channel, q.Oldest, func(msg slack.Message) bool {
+		// History is newest first, so the last match is the oldest
+		if q.matches(msg) {
+			found = &msg
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// findBuildMessageMode is the configured mode, where ThreadBuild is the
+// thread mode unless FindBuildMessage is set.
+func (p Plugin) findBuildMessageMode() string {
+	if p.Config.FindBuildMessage == "" && p.Config.ThreadBuild {
+		return FindBuildMessageThread
+	}
+	return p.Config.FindBuildMessage
+}
+
+// historyChannel is the ID of the channel whose history is searched for
+// earlier messages. conversations.history only takes IDs, and messages to a
+// recipient go to a conversation that isn't Channel.
+func (p Plugin) historyChannel() (string, error) {
+	if p.Config.Recipient != "" {
+		return "", errors.New("searching the channel history needs a channel, not a recipient")
+	}
+	if !isChannelID(p.Config.Channel) {
+		return "", fmt.Errorf("searching the channel history needs a channel ID like C0123456789, not %q", p.Config.Channel)
+	}
+	return p.Config.Channel, nil
+}
+
+// isChannelID reports whether s looks like the ID of a public or private
+// channel or a direct message.
+func isChannelID(s string) bool {
+	if len(s) < 2 || !strings.ContainsAny(s[:1], "CDG") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !('A' <= s[i] && s[i] <= 'Z') && !('0' <= s[i] && s[i] <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// findBuildMessage returns the channel ID and ts of the message this bot
+// posted for the same build, or an empty ts when there is none.
+func (p Plugin) findBuildMessage() (string, string, error) {
+	channelID, err := p.historyChannel()
+	if err != nil {
+		return "", "", err
+	}
+
+	api := p.slackClient()
+	auth, err := api.AuthTest()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to authenticate using access token: %w", err)
+	}
+
+	lookBack := p.Config.LookBack
+	if lookBack <= 0 {
+		lookBack = DefaultLookBack
+	}
+
+	msg, err := findBuildMessage(api, buildMessageQuery{
+		Channel: channelID,
+		Repo:    p.Repo.fullName(),
+		Number:  p.Build.Number,
+		Link:    p.Build.Link,
+		BotID:   auth.BotID,
+		Oldest:  time.Now().Add(-lookBack),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if msg == nil {
+		log.Printf("No earlier message found for build %d, posting a new one", p.Build.Number)
+		return channelID, "", nil
+	}
+
+	log.Printf("Found earlier message %s for build %d", msg.Timestamp, p.Build.Number)
+	return channelID, msg.Timestamp, nil
+}
+
+func (p Plugin) updateMessage(channelID, ts string, options []slack.MsgOption) (string, string, error) {
+	start := time.Now()
+	api := p.slackClient()
+
+	respChannel, respTs, _, err := api.UpdateMessage(channelID, ts, options...)
+	if err != nil {
+		p.report.addMessage(MessageRecord{
+			Timing:    timingSince(start),
+			Channel:   channelID,
+			Timestamp: ts,
+			Updated:   true,
+			Error:     err.Error(),
+		})
+		return "", "", fmt.Errorf("failed to update message %s: %w", ts, err)
+	}
+
+	p.report.addMessage(MessageRecord{
+		Timing:    timingSince(start),
+		Channel:   respChannel,
+		Timestamp: respTs,
+		Updated:   true,
+		Permalink: p.permalink(api, respChannel, respTs),
+	})
+	return respChannel, respTs, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+// historyPages is channel history newest first, split the way a paging
+// conversations.history would return it.
+var historyPages = map[string]string{
+	"": `{"ok":true,"has_more":true,"response_metadata":{"next_cursor":"page2"},"messages":[
+		{"ts":"300.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","build_number":2}}},
+		{"ts":"200.000003","bot_id":"B2","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","build_number":1}}},
+		{"ts":"200.000002","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","build_number":1}}}
+	]}`,
+	"page2": `{"ok":true,"has_more":true,"response_metadata":{"next_cursor":"page3"},"messages":[
+		{"ts":"200.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","build_number":1}}},
+		{"ts":"150.000002","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/other","build_number":1}}},
+		{"ts":"150.000001","bot_id":"B1","text":"*success* <https://drone.example.com/octocat/hello-world/12|octocat/hello-world#7fd1a60b>"}
+	]}`,
+	"page3": `{"ok":true,"has_more":false,"messages":[
+		{"ts":"100.000002","bot_id":"B1","attachments":[{"fallback":"success https://drone.example.com/octocat/hello-world/123"}]},
+		{"ts":"100.000001","user":"U1","text":"https://drone.example.com/octocat/hello-world/12 is broken"}
+	]}`,
+}
+
+func newHistoryServer(t *testing.T, oldest string) (*httptest.Server, *[]string) {
+	var cursors []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, r.ParseForm())
+		assert.Equal(t, r.URL.Path, "/conversations.history")
+		assert.Equal(t, r.PostForm.Get("channel"), "C123")
+		assert.Equal(t, r.PostForm.Get("include_all_metadata"), "1")
+		assert.Equal(t, r.PostForm.Get("oldest"), oldest)
+
+		cursor := r.PostForm.Get("cursor")
+		cursors = append(cursors, cursor)
+		page, ok := historyPages[cursor]
+		assert.Assert(t, ok, "unexpected cursor %s", cursor)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(page))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(server.Close)
+	return server, &cursors
+}
+
+func TestFindBuildMessage(t *testing.T) {
+	oldest := time.Unix(50, 250000)
+	server, cursors := newHistoryServer(t, "50.000250")
+	api := slack.New("test-access-token", slack.OptionAPIURL(server.URL+"/"))
+
+	testCases := map[string]struct {
+		Query  buildMessageQuery
+		Expect string
+	}{
+		"Metadata": {
+			Query:  buildMessageQuery{Repo: "octocat/hello-world", Number: 1, BotID: "B1"},
+			Expect: "200.000001",
+		},
+		"Other Bot": {
+			Query:  buildMessageQuery{Repo: "octocat/hello-world", Number: 1, BotID: "B2"},
+			Expect: "200.000003",
+		},
+		"Fallback Link": {
+			Query: buildMessageQuery{
+				Repo:   "octocat/hello-world",
+				Number: 12,
+				Link:   "https://drone.example.com/octocat/hello-world/12",
+				BotID:  "B1",
+			},
+			Expect: "150.000001",
+		},
+		"Fallback Attachment": {
+			Query: buildMessageQuery{
+				Repo:   "octocat/hello-world",
+				Number: 123,
+				Link:   "https://drone.example.com/octocat/hello-world/123",
+				BotID:  "B1",
+			},
+			Expect: "100.000002",
+		},
+		"Not Found": {
+			Query: buildMessageQuery{Repo: "octocat/hello-world", Number: 3, BotID: "B1"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		*cursors = nil
+		testCase.Query.Channel = "C123"
+		testCase.Query.Oldest = oldest
+
+		msg, err := findBuildMessage(api, testCase.Query)
+		assert.NilError(t, err, name)
+		assert.DeepEqual(t, *cursors, []string{"", "page2", "page3"})
+		if testCase.Expect == "" {
+			assert.Assert(t, msg == nil, name)
+			continue
+		}
+		assert.Assert(t, msg != nil, name)
+		assert.Equal(t, msg.Timestamp, testCase.Expect, name)
+	}
+}
+
+func TestIsChannelID(t *testing.T) {
+	assert.Assert(t, isChannelID("C0123456789"))
+	assert.Assert(t, isChannelID("G0123456789"))
+	assert.Assert(t, isChannelID("D0123456789"))
+	assert.Assert(t, !isChannelID("#builds"))
+	assert.Assert(t, !isChannelID("builds"))
+	assert.Assert(t, !isChannelID("Cbuilds"))
+	assert.Assert(t, !isChannelID(""))
+}
+
+func TestContainsLink(t *testing.T) {
+	link := "https://drone.example.com/octocat/hello-world/12"
+
+	assert.Assert(t, containsLink("build "+link, link))
+	assert.Assert(t, containsLink("<"+link+"|octocat/hello-world>", link))
+	assert.Assert(t, containsLink(link+"3 and "+link+"/", link))
+	assert.Assert(t, !containsLink(link+"3", link))
+	assert.Assert(t, !containsLink("nothing here", link))
+}
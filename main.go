@@ -187,6 +187,166 @@ func main() {
 			Usage:  "job started",
 			EnvVar: "DRONE_JOB_STARTED",
 		},
+		cli.StringFlag{
+			Name:   "repo.link",
+			Usage:  "repository link",
+			EnvVar: "DRONE_REPO_LINK",
+		},
+		cli.StringFlag{
+			Name:   "commit.before",
+			Usage:  "git commit sha before the push",
+			EnvVar: "DRONE_COMMIT_BEFORE",
+		},
+		cli.StringFlag{
+			Name:   "commit.link",
+			Usage:  "commit link",
+			EnvVar: "DRONE_COMMIT_LINK",
+		},
+		cli.Int64Flag{
+			Name:   "build.finished",
+			Usage:  "build finished",
+			EnvVar: "DRONE_BUILD_FINISHED",
+		},
+		cli.StringFlag{
+			Name:   "build.source_branch",
+			Usage:  "source branch of a pull request or promotion",
+			EnvVar: "DRONE_SOURCE_BRANCH",
+		},
+		cli.StringFlag{
+			Name:   "build.target_branch",
+			Usage:  "target branch of a pull request or promotion",
+			EnvVar: "DRONE_TARGET_BRANCH",
+		},
+		cli.StringFlag{
+			Name:   "build.failed_steps",
+			Usage:  "comma separated steps that failed",
+			EnvVar: "DRONE_FAILED_STEPS",
+		},
+		cli.StringFlag{
+			Name:   "build.failed_stages",
+			Usage:  "comma separated stages that failed",
+			EnvVar: "DRONE_FAILED_STAGES",
+		},
+		cli.StringFlag{
+			Name:   "build.calver",
+			Usage:  "calendar version of the tag",
+			EnvVar: "DRONE_CALVER",
+		},
+		cli.StringFlag{
+			Name:   "semver",
+			Usage:  "semantic version of the tag",
+			EnvVar: "DRONE_SEMVER",
+		},
+		cli.StringFlag{
+			Name:   "semver.short",
+			Usage:  "semantic version without pre-release and build metadata",
+			EnvVar: "DRONE_SEMVER_SHORT",
+		},
+		cli.StringFlag{
+			Name:   "semver.major",
+			Usage:  "major version of the tag",
+			EnvVar: "DRONE_SEMVER_MAJOR",
+		},
+		cli.StringFlag{
+			Name:   "semver.minor",
+			Usage:  "minor version of the tag",
+			EnvVar: "DRONE_SEMVER_MINOR",
+		},
+		cli.StringFlag{
+			Name:   "semver.patch",
+			Usage:  "patch version of the tag",
+			EnvVar: "DRONE_SEMVER_PATCH",
+		},
+		cli.StringFlag{
+			Name:   "semver.prerelease",
+			Usage:  "pre-release of the tag",
+			EnvVar: "DRONE_SEMVER_PRERELEASE",
+		},
+		cli.StringFlag{
+			Name:   "semver.build",
+			Usage:  "build metadata of the tag",
+			EnvVar: "DRONE_SEMVER_BUILD",
+		},
+		cli.StringFlag{
+			Name:   "semver.error",
+			Usage:  "error parsing the tag as a semantic version",
+			EnvVar: "DRONE_SEMVER_ERROR",
+		},
+		cli.Int64Flag{
+			Name:   "job.finished",
+			Usage:  "job finished",
+			EnvVar: "DRONE_JOB_FINISHED",
+		},
+		cli.StringFlag{
+			Name:   "stage.name",
+			Usage:  "stage name",
+			EnvVar: "DRONE_STAGE_NAME",
+		},
+		cli.IntFlag{
+			Name:   "stage.number",
+			Usage:  "stage number",
+			EnvVar: "DRONE_STAGE_NUMBER",
+		},
+		cli.StringFlag{
+			Name:   "stage.kind",
+			Usage:  "stage kind",
+			EnvVar: "DRONE_STAGE_KIND",
+		},
+		cli.StringFlag{
+			Name:   "stage.type",
+			Usage:  "stage type",
+			EnvVar: "DRONE_STAGE_TYPE",
+		},
+		cli.StringFlag{
+			Name:   "stage.status",
+			Usage:  "stage status",
+			EnvVar: "DRONE_STAGE_STATUS",
+		},
+		cli.Int64Flag{
+			Name:   "stage.started",
+			Usage:  "stage started",
+			EnvVar: "DRONE_STAGE_STARTED",
+		},
+		cli.Int64Flag{
+			Name:   "stage.finished",
+			Usage:  "stage finished",
+			EnvVar: "DRONE_STAGE_FINISHED",
+		},
+		cli.StringFlag{
+			Name:   "stage.machine",
+			Usage:  "machine running the stage",
+			EnvVar: "DRONE_STAGE_MACHINE",
+		},
+		cli.StringFlag{
+			Name:   "stage.os",
+			Usage:  "stage operating system",
+			EnvVar: "DRONE_STAGE_OS",
+		},
+		cli.StringFlag{
+			Name:   "stage.arch",
+			Usage:  "stage architecture",
+			EnvVar: "DRONE_STAGE_ARCH",
+		},
+		cli.StringFlag{
+			Name:   "stage.variant",
+			Usage:  "stage architecture variant",
+			EnvVar: "DRONE_STAGE_VARIANT",
+		},
+		cli.StringFlag{
+			Name:   "stage.depends_on",
+			Usage:  "comma separated stages the stage depends on",
+			EnvVar: "DRONE_STAGE_DEPENDS_ON",
+		},
+		cli.StringFlag{
+			Name:   "step.name",
+			Usage:  "step name",
+			EnvVar: "DRONE_STEP_NAME",
+		},
+		cli.IntFlag{
+			Name:   "step.number",
+			Usage:  "step number",
+			EnvVar: "DRONE_STEP_NUMBER",
+		},
 		cli.StringFlag{
 			Name:   "custom.block",
 			Usage:  "custom block to send to slack. ",
@@ -236,9 +396,297 @@ func main() {
 		},
 		cli.BoolFlag{
 			Name:   "fail_on_error",
-			Usage:  "fail build on error",
+			Usage:  "fail build on error (deprecated, use error_policy)",
 			EnvVar: "PLUGIN_FAIL_ON_ERROR",
 		},
+		cli.StringFlag{
+			Name:   "committer_delivery",
+			Usage:  "how committers are notified: dm or ephemeral",
+			Value:  CommitterDeliveryDM,
+			EnvVar: "PLUGIN_COMMITTER_DELIVERY",
+		},
+		cli.StringFlag{
+			Name:   "output_file",
+			Usage:  "path of the json report summarising every action taken",
+			EnvVar: "PLUGIN_OUTPUT_FILE",
+		},
+		cli.StringFlag{
+			Name:   "send_at",
+			Usage:  "schedule the message for an RFC3339 time or a duration from now",
+			EnvVar: "PLUGIN_SEND_AT",
+		},
+		cli.StringFlag{
+			Name:   "cancel_scheduled_id",
+			Usage:  "id of a scheduled message to cancel",
+			EnvVar: "PLUGIN_CANCEL_SCHEDULED_ID",
+		},
+		cli.StringFlag{
+			Name:   "scheduled_channel",
+			Usage:  "channel id of the scheduled message to cancel, defaults to the channel",
+			EnvVar: "PLUGIN_SCHEDULED_CHANNEL",
+		},
+		cli.StringFlag{
+			Name:   "message_ts",
+			Usage:  "timestamp of an existing slack message to act on",
+			EnvVar: "PLUGIN_MESSAGE_TS",
+		},
+		cli.BoolFlag{
+			Name:   "reactions",
+			Usage:  "react to an existing message with the build status instead of posting",
+			EnvVar: "PLUGIN_REACTIONS",
+		},
+		cli.StringFlag{
+			Name:   "reaction",
+			Usage:  "emoji to react with, overriding the status mapping",
+			EnvVar: "PLUGIN_REACTION",
+		},
+		cli.StringFlag{
+			Name:   "reaction_emojis",
+			Usage:  "status=emoji pairs overriding the default reactions",
+			EnvVar: "PLUGIN_REACTION_EMOJIS",
+		},
+		cli.BoolFlag{
+			Name:   "pin_releases",
+			Usage:  "pin tag build announcements and unpin the previous one",
+			EnvVar: "PLUGIN_PIN_RELEASES",
+		},
+		cli.StringFlag{
+			Name:   "metadata_event_type",
+			Usage:  "event type of the metadata attached to messages",
+			Value:  DefaultMetadataEventType,
+			EnvVar: "PLUGIN_METADATA_EVENT_TYPE",
+		},
+		cli.StringFlag{
+			Name:   "metadata",
+			Usage:  "extra key=value pairs for the message metadata",
+			EnvVar: "PLUGIN_METADATA",
+		},
+		cli.StringFlag{
+			Name:   "find_build_message",
+			Usage:  "update or thread under the earlier message for the same build: update or thread",
+			EnvVar: "PLUGIN_FIND_BUILD_MESSAGE",
+		},
+		cli.BoolFlag{
+			Name:   "thread_build",
+			Usage:  "reply in the thread of the earlier message for the same build, same as find_build_message=thread",
+			EnvVar: "PLUGIN_THREAD_BUILD",
+		},
+		cli.DurationFlag{
+			Name:   "look_back",
+			Usage:  "how far back to search the channel history for the build message",
+			Value:  DefaultLookBack,
+			EnvVar: "PLUGIN_LOOK_BACK",
+		},
+		cli.BoolFlag{
+			Name:   "notify_on_change",
+			Usage:  "only notify when the build breaks or is fixed",
+			EnvVar: "PLUGIN_NOTIFY_ON_CHANGE",
+		},
+		cli.StringFlag{
+			Name:   "state_file",
+			Usage:  "file on a mounted volume keeping state between builds",
+			EnvVar: "PLUGIN_STATE_FILE",
+		},
+		cli.DurationFlag{
+			Name:   "suppress_window",
+			Usage:  "skip repeated notifications with the same suppress key within this window",
+			EnvVar: "PLUGIN_SUPPRESS_WINDOW",
+		},
+		cli.StringFlag{
+			Name:   "suppress_key",
+			Usage:  "template of the key identifying repeated notifications",
+			Value:  DefaultSuppressKey,
+			EnvVar: "PLUGIN_SUPPRESS_KEY",
+		},
+		cli.StringFlag{
+			Name:   "suppress_mode",
+			Usage:  "what to do with repeated notifications: skip or thread",
+			Value:  SuppressSkip,
+			EnvVar: "PLUGIN_SUPPRESS_MODE",
+		},
+		cli.StringFlag{
+			Name:   "quiet_hours",
+			Usage:  "quiet hours, e.g. 22:00-07:00,Sat-Sun",
+			EnvVar: "PLUGIN_QUIET_HOURS",
+		},
+		cli.StringFlag{
+			Name:   "quiet_timezone",
+			Usage:  "timezone of the quiet hours",
+			Value:  "UTC",
+			EnvVar: "PLUGIN_QUIET_TIMEZONE",
+		},
+		cli.StringFlag{
+			Name:   "quiet_holidays",
+			Usage:  "dates that are quiet all day, e.g. 2024-12-25,2024-12-26",
+			EnvVar: "PLUGIN_QUIET_HOLIDAYS",
+		},
+		cli.StringFlag{
+			Name:   "quiet_action",
+			Usage:  "what to do during quiet hours: defer, nomention, drop or send",
+			Value:  QuietDefer,
+			EnvVar: "PLUGIN_QUIET_ACTION",
+		},
+		cli.StringFlag{
+			Name:   "quiet_actions",
+			Usage:  "quiet hours action per build status, e.g. success=drop,failure=nomention",
+			EnvVar: "PLUGIN_QUIET_ACTIONS",
+		},
+		cli.StringFlag{
+			Name:   "quiet_bypass_branches",
+			Usage:  "branches whose failures are sent during quiet hours",
+			Value:  DefaultQuietBypassBranches,
+			EnvVar: "PLUGIN_QUIET_BYPASS_BRANCHES",
+		},
+		cli.StringFlag{
+			Name:   "api_url",
+			Usage:  "base URL of the Slack Web API",
+			EnvVar: "PLUGIN_API_URL",
+		},
+		cli.StringFlag{
+			Name:   "proxy",
+			Usage:  "proxy for Slack, webhook and template requests, defaults to HTTP_PROXY/HTTPS_PROXY",
+			EnvVar: "PLUGIN_PROXY",
+		},
+		cli.StringFlag{
+			Name:   "ca_cert",
+			Usage:  "path to a PEM CA bundle trusted in addition to the system roots",
+			EnvVar: "PLUGIN_CA_CERT",
+		},
+		cli.DurationFlag{
+			Name:   "http_timeout",
+			Usage:  "timeout of every HTTP request",
+			Value:  DefaultHTTPTimeout,
+			EnvVar: "PLUGIN_HTTP_TIMEOUT",
+		},
+		cli.StringFlag{
+			Name:   "user_agent",
+			Usage:  "User-Agent header of every HTTP request",
+			EnvVar: "PLUGIN_USER_AGENT",
+		},
+		cli.StringFlag{
+			Name:   "template_headers",
+			Usage:  "comma separated Name=value headers sent when fetching remote templates",
+			EnvVar: "PLUGIN_TEMPLATE_HEADERS",
+		},
+		cli.StringFlag{
+			Name:   "template_sha256",
+			Usage:  "SHA-256 checksum the remote template must match",
+			EnvVar: "PLUGIN_TEMPLATE_SHA256",
+		},
+		cli.Int64Flag{
+			Name:   "template_max_size",
+			Usage:  "maximum size of a remote template in bytes",
+			Value:  DefaultTemplateMaxSize,
+			EnvVar: "PLUGIN_TEMPLATE_MAX_SIZE",
+		},
+		cli.DurationFlag{
+			Name:   "template_timeout",
+			Usage:  "timeout of fetching a remote template",
+			Value:  DefaultTemplateTimeout,
+			EnvVar: "PLUGIN_TEMPLATE_TIMEOUT",
+		},
+		cli.StringFlag{
+			Name:   "template_cache_dir",
+			Usage:  "directory remote templates are cached in and revalidated from",
+			EnvVar: "PLUGIN_TEMPLATE_CACHE_DIR",
+		},
+		cli.BoolFlag{
+			Name:   "template_strict",
+			Usage:  "fail when a template, fallback or custom block looks like a path to a missing file",
+			EnvVar: "PLUGIN_TEMPLATE_STRICT",
+		},
+		cli.BoolFlag{
+			Name:   "changelog",
+			Usage:  "add the commits since the previous tag to tag build messages",
+			EnvVar: "PLUGIN_CHANGELOG",
+		},
+		cli.IntFlag{
+			Name:   "changelog_limit",
+			Usage:  "maximum number of changelog entries shown",
+			Value:  DefaultChangelogLimit,
+			EnvVar: "PLUGIN_CHANGELOG_LIMIT",
+		},
+		cli.BoolFlag{
+			Name:   "changes",
+			Usage:  "summarise the changed files per directory for templates",
+			EnvVar: "PLUGIN_CHANGES",
+		},
+		cli.BoolFlag{
+			Name:   "changes_block",
+			Usage:  "add the changed files summary to the message as a context block",
+			EnvVar: "PLUGIN_CHANGES_BLOCK",
+		},
+		cli.StringFlag{
+			Name:   "changes_groups",
+			Usage:  "comma separated name=pattern rules grouping changed files",
+			EnvVar: "PLUGIN_CHANGES_GROUPS",
+		},
+		cli.IntFlag{
+			Name:   "changes_depth",
+			Usage:  "directory depth changed files are grouped by",
+			Value:  DefaultChangesDepth,
+			EnvVar: "PLUGIN_CHANGES_DEPTH",
+		},
+		cli.IntFlag{
+			Name:   "changes_limit",
+			Usage:  "maximum number of changed directories shown",
+			Value:  DefaultChangesLimit,
+			EnvVar: "PLUGIN_CHANGES_LIMIT",
+		},
+		cli.StringFlag{
+			Name:   "junit_reports",
+			Usage:  "comma separated globs of JUnit XML reports to summarise",
+			EnvVar: "PLUGIN_JUNIT_REPORTS",
+		},
+		cli.IntFlag{
+			Name:   "junit_limit",
+			Usage:  "maximum number of failed tests shown",
+			Value:  DefaultJUnitLimit,
+			EnvVar: "PLUGIN_JUNIT_LIMIT",
+		},
+		cli.StringFlag{
+			Name:   "go_test_reports",
+			Usage:  "comma separated globs of go test -json output to summarise",
+			EnvVar: "PLUGIN_GO_TEST_REPORTS",
+		},
+		cli.IntFlag{
+			Name:   "go_test_limit",
+			Usage:  "maximum number of slowest tests listed",
+			Value:  DefaultGoTestLimit,
+			EnvVar: "PLUGIN_GO_TEST_LIMIT",
+		},
+		cli.StringFlag{
+			Name:   "coverage_profiles",
+			Usage:  "comma separated globs of Go coverage profiles to summarise",
+			EnvVar: "PLUGIN_COVERAGE_PROFILES",
+		},
+		cli.StringFlag{
+			Name:   "coverage_baseline",
+			Usage:  "coverage profile or percentage file to compare the coverage with",
+			EnvVar: "PLUGIN_COVERAGE_BASELINE",
+		},
+		cli.IntFlag{
+			Name:   "coverage_limit",
+			Usage:  "maximum number of packages listed with their coverage",
+			Value:  DefaultCoverageLimit,
+			EnvVar: "PLUGIN_COVERAGE_LIMIT",
+		},
+		cli.StringFlag{
+			Name:   "transport",
+			Usage:  "chat service of the webhook: slack, mattermost, teams, discord or googlechat",
+			Value:  TransportSlack,
+			EnvVar: "PLUGIN_TRANSPORT",
+		},
+		cli.StringFlag{
+			Name:   "user_map",
+			Usage:  "Slack user IDs to usernames on other chat services, e.g. U123=octocat",
+			EnvVar: "PLUGIN_USER_MAP",
+		},
+		cli.StringFlag{
+			Name:   "error_policy",
+			Usage:  "how failed slack operations affect the build: fail, warn or ignore",
+			EnvVar: "PLUGIN_ERROR_POLICY",
+		},
 		cli.StringFlag{
 			Name:   "slack_id_of",
 			Usage:  "slack id required for the user email id",
@@ -249,6 +697,11 @@ func main() {
 			Usage:  "git repo path holding the committers email id to fetch slack IDs from",
 			EnvVar: "PLUGIN_GIT_REPO_PATH",
 		},
+		cli.StringFlag{
+			Name:   "git_path",
+			Usage:  "git repo path of the build for releases, changelogs and changes",
+			EnvVar: "PLUGIN_GIT_PATH",
+		},
 		cli.BoolFlag{
 			Name:   "plugin_committer_slack_id",
 			Usage:  "flag to enable fetching slack IDs from the committers list",
@@ -266,10 +719,16 @@ func main() {
 }
 
 func run(c *cli.Context) error {
+	errorPolicy, err := resolveErrorPolicy(c.String("error_policy"), c.Bool("fail_on_error"))
+	if err != nil {
+		return err
+	}
+
 	plugin := Plugin{
 		Repo: Repo{
 			Owner: c.String("repo.owner"),
 			Name:  c.String("repo.name"),
+			Link:  c.String("repo.link"),
 		},
 		Build: Build{
 			Tag:    c.String("build.tag"),
@@ -286,16 +745,54 @@ func run(c *cli.Context) error {
 				Email:    c.String("commit.author.email"),
 				Avatar:   c.String("commit.author.avatar"),
 			},
-			Pull:     c.String("commit.pull"),
-			Message:  newCommitMessage(c.String("commit.message")),
-			DeployTo: c.String("build.deployTo"),
-			Link:     c.String("build.link"),
-			Started:  c.Int64("build.started"),
-			Created:  c.Int64("build.created"),
+			Pull:         c.String("commit.pull"),
+			Message:      newCommitMessage(c.String("commit.message")),
+			DeployTo:     c.String("build.deployTo"),
+			Link:         c.String("build.link"),
+			Started:      c.Int64("build.started"),
+			Created:      c.Int64("build.created"),
+			Finished:     c.Int64("build.finished"),
+			SourceBranch: c.String("build.source_branch"),
+			TargetBranch: c.String("build.target_branch"),
+			CommitLink:   c.String("commit.link"),
+			Before:       c.String("commit.before"),
+			FailedSteps:  splitList(c.String("build.failed_steps")),
+			FailedStages: splitList(c.String("build.failed_stages")),
+			Semver: Semver{
+				Version:    c.String("semver"),
+				Short:      c.String("semver.short"),
+				Major:      c.String("semver.major"),
+				Minor:      c.String("semver.minor"),
+				Patch:      c.String("semver.patch"),
+				Prerelease: c.String("semver.prerelease"),
+				Build:      c.String("semver.build"),
+				Error:      c.String("semver.error"),
+			},
+			Calver: c.String("build.calver"),
 		},
 		Job: Job{
-			Started: c.Int64("job.started"),
-		},
+			Started:  c.Int64("job.started"),
+			Finished: c.Int64("job.finished"),
+		},
+		Stage: Stage{
+			Name:      c.String("stage.name"),
+			Number:    c.Int("stage.number"),
+			Kind:      c.String("stage.kind"),
+			Type:      c.String("stage.type"),
+			Status:    c.String("stage.status"),
+			Started:   c.Int64("stage.started"),
+			Finished:  c.Int64("stage.finished"),
+			Machine:   c.String("stage.machine"),
+			OS:        c.String("stage.os"),
+			Arch:      c.String("stage.arch"),
+			Variant:   c.String("stage.variant"),
+			DependsOn: splitList(c.String("stage.depends_on")),
+		},
+		Step: Step{
+			Name:   c.String("step.name"),
+			Number: c.Int("step.number"),
+		},
+		Env: droneEnv(os.Environ()),
 		Config: Config{
 			Webhook:        c.String("webhook"),
 			Channel:        c.String("channel"),
@@ -318,16 +815,109 @@ func run(c *cli.Context) error {
 			FileName:             c.String("filename"),
 			Title:                c.String("title"),
 			InitialComment:       c.String("initial_comment"),
-			FailOnError:          c.Bool("fail_on_error"),
+			ErrorPolicy:          errorPolicy,
 			SlackIdOf:            c.String("slack_id_of"),
 			CommitterListGitPath: c.String("committer_list_git_path"),
+			GitPath:              c.String("git_path"),
 			CommitterSlackId:     c.Bool("plugin_committer_slack_id"),
+			CommitterDelivery:    c.String("committer_delivery"),
+			OutputFile:           c.String("output_file"),
+			SendAt:               c.String("send_at"),
+			CancelScheduledID:    c.String("cancel_scheduled_id"),
+			ScheduledChannel:     c.String("scheduled_channel"),
+			MessageTs:            c.String("message_ts"),
+			Reactions:            c.Bool("reactions"),
+			Reaction:             c.String("reaction"),
+			ReactionEmojis:       c.String("reaction_emojis"),
+			PinReleases:          c.Bool("pin_releases"),
+			MetadataEventType:    c.String("metadata_event_type"),
+			Metadata:             c.String("metadata"),
+			FindBuildMessage:     c.String("find_build_message"),
+			ThreadBuild:          c.Bool("thread_build"),
+			LookBack:             c.Duration("look_back"),
+			NotifyOnChange:       c.Bool("notify_on_change"),
+			StateFile:            c.String("state_file"),
+			SuppressWindow:       c.Duration("suppress_window"),
+			SuppressKey:          c.String("suppress_key"),
+			SuppressMode:         c.String("suppress_mode"),
+			QuietHours:           c.String("quiet_hours"),
+			QuietTimezone:        c.String("quiet_timezone"),
+			QuietHolidays:        c.String("quiet_holidays"),
+			QuietAction:          c.String("quiet_action"),
+			QuietActions:         c.String("quiet_actions"),
+			QuietBypassBranches:  c.String("quiet_bypass_branches"),
+			APIURL:               c.String("api_url"),
+			Transport:            c.String("transport"),
+			UserMap:              c.String("user_map"),
+			Proxy:                c.String("proxy"),
+			CACert:               c.String("ca_cert"),
+			HTTPTimeout:          c.Duration("http_timeout"),
+			UserAgent:            c.String("user_agent"),
+			TemplateHeaders:      c.String("template_headers"),
+			TemplateSHA256:       c.String("template_sha256"),
+			TemplateMaxSize:      c.Int64("template_max_size"),
+			TemplateTimeout:      c.Duration("template_timeout"),
+			TemplateCacheDir:     c.String("template_cache_dir"),
+			TemplateStrict:       c.Bool("template_strict"),
+			Changelog:            c.Bool("changelog"),
+			ChangelogLimit:       c.Int("changelog_limit"),
+			Changes:              c.Bool("changes"),
+			ChangesBlock:         c.Bool("changes_block"),
+			ChangesGroups:        c.String("changes_groups"),
+			ChangesDepth:         c.Int("changes_depth"),
+			ChangesLimit:         c.Int("changes_limit"),
+			JUnitReports:         c.String("junit_reports"),
+			JUnitLimit:           c.Int("junit_limit"),
+			GoTestReports:        c.String("go_test_reports"),
+			GoTestLimit:          c.Int("go_test_limit"),
+			CoverageProfiles:     c.String("coverage_profiles"),
+			CoverageBaseline:     c.String("coverage_baseline"),
+			CoverageLimit:        c.Int("coverage_limit"),
 		},
 	}
 
 	if plugin.Build.Commit == "" {
 		plugin.Build.Commit = "0000000000000000000000000000000000000000"
 	}
+	switch plugin.Config.CommitterDelivery {
+	case "", CommitterDeliveryDM, CommitterDeliveryEphemeral:
+	default:
+		return fmt.Errorf("invalid committer delivery %q, must be dm or ephemeral", plugin.Config.CommitterDelivery)
+	}
+	switch plugin.Config.FindBuildMessage {
+	case "", FindBuildMessageUpdate, FindBuildMessageThread:
+	default:
+		return fmt.Errorf("invalid find build message mode %q, must be update or thread", plugin.Config.FindBuildMessage)
+	}
+	historyLookup := plugin.findBuildMessageMode() != "" ||
+		((plugin.Config.NotifyOnChange || plugin.Config.SuppressWindow > 0) && plugin.Config.StateFile == "")
+	if historyLookup && plugin.Config.AccessToken != "" {
+		if _, err := plugin.historyChannel(); err != nil {
+			return err
+		}
+	}
+	switch plugin.Config.SuppressMode {
+	case "", SuppressSkip, SuppressThread:
+	default:
+		return fmt.Errorf("invalid suppress mode %q, must be skip or thread", plugin.Config.SuppressMode)
+	}
+	switch plugin.Config.QuietAction {
+	case "", QuietDefer, QuietNoMention, QuietDrop, QuietSend:
+	default:
+		return fmt.Errorf("invalid quiet action %q, must be defer, nomention, drop or send", plugin.Config.QuietAction)
+	}
+	switch plugin.Config.Transport {
+	case "", TransportSlack:
+	case TransportMattermost, TransportTeams, TransportDiscord, TransportGoogleChat:
+		if plugin.Config.Webhook == "" {
+			return fmt.Errorf("the %s transport needs a webhook url", plugin.Config.Transport)
+		}
+	default:
+		return fmt.Errorf("invalid transport %q, must be slack, mattermost, teams, discord or googlechat", plugin.Config.Transport)
+	}
+	if err := checkTemplateSHA256(plugin.Config.Template, plugin.Config.TemplateSHA256); err != nil {
+		return err
+	}
 	if plugin.Config.Webhook == "" && plugin.Config.AccessToken == "" {
 		return errors.New("you must provide a webhook url or access token")
 	}
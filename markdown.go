@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+var (
+	linkPattern   = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9+.-]*:[^<>|]*)(?:\|([^<>]*))?>`)
+	boldPattern   = regexp.MustCompile(`(^|[\s(_~])\*([^*\n]+)\*`)
+	strikePattern = regexp.MustCompile(`(^|[\s(_*])~([^~\n]+)~`)
+	italicPattern = regexp.MustCompile(`(^|[\s(*~>])_([^_\n]+)_`)
+
+	placeholderPattern = regexp.MustCompile("\x00[0-9]+\x00")
+)
+
+// slackToMarkdown converts Slack mrkdwn to the Markdown most other chat
+// services understand. Mentions of Slack user IDs become @username through
+// users, or keep the ID when it isn't mapped.
+func slackToMarkdown(text string, users map[string]string) string {
+	text = convertMentions(text, users)
+
+	text = linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		parts := linkPattern.FindStringSubmatch(link)
+		if parts[2] == "" {
+			return parts[1]
+		}
+		return "[" + parts[2] + "](" + parts[1] + ")"
+	})
+
+	text = boldPattern.ReplaceAllString(text, "$1**$2**")
+	text = strikePattern.ReplaceAllString(text, "$1~~$2~~")
+
+	return unescapeSlack(text)
+}
+
+// slackToHTML converts Slack mrkdwn to the HTML subset of Google Chat cards.
+func slackToHTML(text string, users map[string]string) string {
+	text = convertMentions(text, users)
+
+	// Links are set aside so their URLs aren't escaped or formatted
+	var links []string
+	text = linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		parts := linkPattern.FindStringSubmatch(link)
+		label := parts[2]
+		if label == "" {
+			label = parts[1]
+		}
+		links = append(links, `<a href="`+html.EscapeString(unescapeSlack(parts[1]))+`">`+html.EscapeString(unescapeSlack(label))+`</a>`)
+		return fmt.Sprintf("\x00%d\x00", len(links)-1)
+	})
+
+	text = html.EscapeString(unescapeSlack(text))
+	text = boldPattern.ReplaceAllString(text, "$1<b>$2</b>")
+	text = italicPattern.ReplaceAllString(text, "$1<i>$2</i>")
+	text = strikePattern.ReplaceAllString(text, "$1<s>$2</s>")
+	text = strings.ReplaceAll(text, "\n", "<br>")
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		i, _ := strconv.Atoi(strings.Trim(placeholder, "\x00"))
+		return links[i]
+	})
+}
+
+// convertMentions turns mentions into @username through users, or into plain
+// text when the user isn't mapped.
+func convertMentions(text string, users map[string]string) string {
+	return mentionPattern.ReplaceAllStringFunc(text, func(mention string) string {
+		parts := mentionPattern.FindStringSubmatch(mention)
+		if parts[1] == "@" {
+			if name, ok := users[parts[2]]; ok {
+				return prepend("@", name)
+			}
+		}
+		return stripMentions(mention)
+	})
+}
+
+// unescapeSlack undoes the three escapes Slack uses in message text.
+func unescapeSlack(text string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+}
+
+// splitHeader takes the text of the first header block out of blocks, for
+// services that show a title separately.
+func splitHeader(blocks []slack.Block) (string, []slack.Block) {
+	for i, block := range blocks {
+		if header, ok := block.(*slack.HeaderBlock); ok && header.Text != nil {
+			rest := append(append([]slack.Block{}, blocks[:i]...), blocks[i+1:]...)
+			return header.Text.Text, rest
+		}
+	}
+	return "", blocks
+}
+
+// truncate shortens s to at most n runes for services with length limits.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// blockField is a label and value pair taken from section block fields.
+type blockField struct {
+	Title string
+	Value string
+}
+
+// blocksToText degrades Block Kit blocks into mrkdwn text and fields for
+// services without Block Kit support.
+func blocksToText(blocks []slack.Block) (string, []blockField) {
+	var lines []string
+	var fields []blockField
+
+	for _, block := range blocks {
+		switch block := block.(type) {
+		case *slack.HeaderBlock:
+			if block.Text != nil {
+				lines = append(lines, "*"+block.Text.Text+"*")
+			}
+		case *slack.SectionBlock:
+			if block.Text != nil {
+				lines = append(lines, block.Text.Text)
+			}
+			for _, field := range block.Fields {
+				fields = append(fields, splitField(field.Text))
+			}
+		case *slack.DividerBlock:
+			lines = append(lines, "---")
+		case *slack.ContextBlock:
+			var parts []string
+			for _, element := range block.ContextElements.Elements {
+				if text, ok := element.(*slack.TextBlockObject); ok {
+					parts = append(parts, text.Text)
+				}
+			}
+			if len(parts) > 0 {
+				lines = append(lines, "_"+strings.Join(parts, " ")+"_")
+			}
+		case *slack.ActionBlock:
+			var links []string
+			for _, element := range block.Elements.ElementSet {
+				if button, ok := element.(*slack.ButtonBlockElement); ok && button.URL != "" && button.Text != nil {
+					links = append(links, "<"+button.URL+"|"+button.Text.Text+">")
+				}
+			}
+			if len(links) > 0 {
+				lines = append(lines, strings.Join(links, " | "))
+			}
+		case *slack.ImageBlock:
+			lines = append(lines, "<"+block.ImageURL+"|"+block.AltText+">")
+		}
+	}
+
+	return strings.Join(lines, "\n"), fields
+}
+
+var inlineFieldPattern = regexp.MustCompile(`^\*([^*\n]+?):?\*:?\s+(.*)$`)
+
+// splitField reads a field like "*Branch:*\nmain" or "*Branch*: main" as a
+// title and value.
+func splitField(text string) blockField {
+	if title, value, ok := strings.Cut(text, "\n"); ok {
+		title = strings.TrimSuffix(strings.Trim(title, "*_ "), ":")
+		return blockField{Title: title, Value: value}
+	}
+	if parts := inlineFieldPattern.FindStringSubmatch(text); parts != nil {
+		return blockField{Title: parts[1], Value: parts[2]}
+	}
+	return blockField{Value: text}
+}
+
+// emojiShortcodes are the shortcodes used by the built-in templates and
+// reactions, for services that don't understand shortcodes.
+var emojiShortcodes = map[string]string{
+	"red_circle":         "\U0001F534",
+	"large_green_circle": "\U0001F7E2",
+	"tada":               "\U0001F389",
+	"raised_hand":        "\u270B",
+	"white_check_mark":   "\u2705",
+	"x":                  "\u274C",
+	"hourglass":          "\u231B",
+	"warning":            "\u26A0\uFE0F",
+	"rocket":             "\U0001F680",
+}
+
+var shortcodePattern = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+
+// replaceEmoji turns known emoji shortcodes into emoji.
+func replaceEmoji(text string) string {
+	return shortcodePattern.ReplaceAllStringFunc(text, func(code string) string {
+		if emoji, ok := emojiShortcodes[strings.Trim(code, ":")]; ok {
+			return emoji
+		}
+		return code
+	})
+}
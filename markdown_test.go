@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestSlackToMarkdown(t *testing.T) {
+	users := map[string]string{"U123": "octocat"}
+
+	testCases := []struct {
+		Text   string
+		Expect string
+	}{
+		{"*success* <http://ci/1|octocat/hello-world#7fd1a60b> (master)", "**success** [octocat/hello-world#7fd1a60b](http://ci/1) (master)"},
+		{"<@U123>: build failed", "@octocat: build failed"},
+		{"<@U999> and <!here>", "@U999 and @here"},
+		{"see <https://example.com>", "see https://example.com"},
+		{"~flaky~ and _slow_", "~~flaky~~ and _slow_"},
+		{"a*b*c stays", "a*b*c stays"},
+		{"1 &lt; 2 &amp;&amp; 3 &gt; 2", "1 < 2 && 3 > 2"},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, slackToMarkdown(testCase.Text, users), testCase.Expect)
+	}
+}
+
+func TestBlocksToText(t *testing.T) {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "Build failed", false, false)),
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "octocat/hello-world", false, false),
+			[]*slack.TextBlockObject{
+				slack.NewTextBlockObject(slack.MarkdownType, "*Branch:*\nmaster", false, false),
+				slack.NewTextBlockObject(slack.MarkdownType, "plain", false, false),
+			},
+			nil,
+		),
+		slack.NewDividerBlock(),
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, "by octocat", false, false)),
+		slack.NewActionBlock("", slack.NewButtonBlockElement("", "", slack.NewTextBlockObject(slack.PlainTextType, "Open", false, false)).WithURL("http://ci/1")),
+	}
+
+	text, fields := blocksToText(blocks)
+	assert.Equal(t, text, "*Build failed*\noctocat/hello-world\n---\n_by octocat_\n<http://ci/1|Open>")
+	assert.DeepEqual(t, fields, []blockField{
+		{Title: "Branch", Value: "master"},
+		{Value: "plain"},
+	})
+}
+
+func TestSlackToHTML(t *testing.T) {
+	users := map[string]string{"U123": "octocat"}
+
+	testCases := []struct {
+		Text   string
+		Expect string
+	}{
+		{"*success* <http://ci/1|build #1>", `<b>success</b> <a href="http://ci/1">build #1</a>`},
+		{"<@U123> broke _main_", "@octocat broke <i>main</i>"},
+		{"~flaky~\nsee <https://example.com>", `<s>flaky</s><br>see <a href="https://example.com">https://example.com</a>`},
+		{"<script> & *x*", "&lt;script&gt; &amp; <b>x</b>"},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, slackToHTML(testCase.Text, users), testCase.Expect)
+	}
+}
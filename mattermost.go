@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+type (
+	// mattermostPayload is the body of a Mattermost incoming webhook.
+	mattermostPayload struct {
+		Channel     string                 `json:"channel,omitempty"`
+		Username    string                 `json:"username,omitempty"`
+		IconURL     string                 `json:"icon_url,omitempty"`
+		IconEmoji   string                 `json:"icon_emoji,omitempty"`
+		Text        string                 `json:"text,omitempty"`
+		Attachments []mattermostAttachment `json:"attachments,omitempty"`
+	}
+
+	mattermostAttachment struct {
+		Fallback string            `json:"fallback,omitempty"`
+		Color    string            `json:"color,omitempty"`
+		Text     string            `json:"text,omitempty"`
+		ImageURL string            `json:"image_url,omitempty"`
+		Fields   []mattermostField `json:"fields,omitempty"`
+	}
+
+	mattermostField struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+		Short bool   `json:"short"`
+	}
+)
+
+// mattermostTransport posts to a Mattermost incoming webhook. Mattermost
+// renders Markdown instead of mrkdwn and has no Block Kit, so blocks are
+// degraded into the attachment text and fields.
+type mattermostTransport struct {
+	webhook string
+	client  *http.Client
+	// Slack user IDs to Mattermost usernames
+	users map[string]string
+}
+
+func (t mattermostTransport) Send(n Notification) error {
+	return postJSON(t.client, t.webhook, t.payload(n))
+}
+
+func (t mattermostTransport) payload(n Notification) mattermostPayload {
+	attachment := mattermostAttachment{
+		Fallback: slackToMarkdown(n.Fallback, t.users),
+		Color:    hexColor(n.Color),
+		Text:     slackToMarkdown(n.Text, t.users),
+		ImageURL: n.ImageURL,
+	}
+
+	if len(n.Blocks) > 0 {
+		text, fields := blocksToText(n.Blocks)
+		if attachment.Text != "" {
+			text = n.Text + "\n" + text
+		}
+		attachment.Text = slackToMarkdown(text, t.users)
+		for _, field := range fields {
+			attachment.Fields = append(attachment.Fields, mattermostField{
+				Title: slackToMarkdown(field.Title, t.users),
+				Value: slackToMarkdown(field.Value, t.users),
+				Short: true,
+			})
+		}
+	}
+
+	return mattermostPayload{
+		// Mattermost takes the channel name without the #, or @user
+		Channel:     strings.TrimPrefix(n.Channel, "#"),
+		Username:    n.Username,
+		IconURL:     n.IconURL,
+		IconEmoji:   strings.Trim(n.IconEmoji, ":"),
+		Attachments: []mattermostAttachment{attachment},
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestMattermostPayload(t *testing.T) {
+	transport := mattermostTransport{users: map[string]string{"U123": "octocat"}}
+
+	payload := transport.payload(Notification{
+		Channel:   "#builds",
+		Username:  "drone",
+		IconEmoji: ":robot_face:",
+		Text:      "<@U123>: *failure* <http://ci/1|build 1>",
+		Fallback:  "failure build 1",
+		Color:     "danger",
+		Blocks: []slack.Block{
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, "Tests failed", false, false),
+				[]*slack.TextBlockObject{slack.NewTextBlockObject(slack.MarkdownType, "*Branch:*\nmaster", false, false)},
+				nil,
+			),
+		},
+	})
+
+	assert.DeepEqual(t, payload, mattermostPayload{
+		Channel:   "builds",
+		Username:  "drone",
+		IconEmoji: "robot_face",
+		Attachments: []mattermostAttachment{{
+			Fallback: "failure build 1",
+			Color:    "#a30200",
+			Text:     "@octocat: **failure** [build 1](http://ci/1)\nTests failed",
+			Fields:   []mattermostField{{Title: "Branch", Value: "master", Short: true}},
+		}},
+	})
+}
+
+func TestExecMattermost(t *testing.T) {
+	var payload mattermostPayload
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&payload))
+		_, _ = w.Write([]byte("ok"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = server.URL
+	plugin.Config.Transport = TransportMattermost
+	plugin.Config.Channel = "builds"
+	plugin.Config.Mentions = "U123"
+	plugin.Config.UserMap = "U123=octocat"
+	plugin.Config.Template = "*{{build.status}}* <{{build.link}}|build {{build.number}}>"
+
+	assert.NilError(t, plugin.Exec())
+	assert.Equal(t, payload.Channel, "builds")
+	assert.Equal(t, len(payload.Attachments), 1)
+	assert.Equal(t, payload.Attachments[0].Color, "#2eb886")
+	assert.Equal(t, payload.Attachments[0].Text, "@octocat: **success** [build 1](http://github.com/octocat/hello-world)")
+}
+
+func TestExecMattermostError(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unable to parse incoming data", http.StatusBadRequest)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = server.URL
+	plugin.Config.Transport = TransportMattermost
+	plugin.Config.ErrorPolicy = ErrorPolicyFail
+
+	assert.ErrorContains(t, plugin.Exec(), "Unable to parse incoming data")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/slack-go/slack"
+)
+
+// DefaultMetadataEventType is the event type of the metadata attached to
+// every message unless Config.MetadataEventType overrides it.
+const DefaultMetadataEventType = "drone_build"
+
+// Keys of the metadata payload built from the repo and build.
+const (
+	metadataRepo        = "repo"
+	metadataBuildNumber = "build_number"
+	metadataCommit      = "commit"
+	metadataBranch      = "branch"
+	metadataEvent       = "event"
+	metadataStatus      = "status"
+	metadataLink        = "link"
+	metadataTag         = "tag"
+	metadataChange      = "change"
+)
+
+func (r Repo) fullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// messageMetadata describes the build so workflows and bots can read it from
+// the message, and so the plugin can find its own messages again.
+func (p Plugin) messageMetadata() (slack.SlackMetadata, error) {
+	eventType := p.Config.MetadataEventType
+	if eventType == "" {
+		eventType = DefaultMetadataEventType
+	}
+
+	extra, err := parsePairs(p.Config.Metadata)
+	if err != nil {
+		return slack.SlackMetadata{}, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	payload := map[string]interface{}{}
+	for k, v := range extra {
+		payload[k] = v
+	}
+
+	// Build data always wins over extra pairs so lookups stay reliable
+	payload[metadataRepo] = p.Repo.fullName()
+	payload[metadataBuildNumber] = p.Build.Number
+	payload[metadataCommit] = p.Build.Commit
+	payload[metadataBranch] = p.Build.Branch
+	payload[metadataEvent] = p.Build.Event
+	payload[metadataStatus] = p.Build.Status
+	payload[metadataLink] = p.Build.Link
+	if p.Build.Tag != "" {
+		payload[metadataTag] = p.Build.Tag
+	}
+	if p.Build.Change != "" {
+		payload[metadataChange] = p.Build.Change
+	}
+	if p.suppressKey != "" {
+		payload[metadataSuppressKey] = p.suppressKey
+	}
+
+	return slack.SlackMetadata{
+		EventType:    eventType,
+		EventPayload: payload,
+	}, nil
+}
+
+// isBuildMessage reports whether the metadata was attached by the plugin for
+// the given repository and build number.
+func isBuildMessage(metadata slack.SlackMetadata, repo string, number int) bool {
+	payload := metadata.EventPayload
+	if payload == nil || payload[metadataRepo] != repo {
+		return false
+	}
+	n, ok := buildNumber(payload)
+	return ok && n == number
+}
+
+// buildNumber reads the build number of a metadata payload. Slack returns it
+// as a JSON number, which decodes to float64.
+func buildNumber(payload map[string]interface{}) (int, bool) {
+	switch n := payload[metadataBuildNumber].(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), n == float64(int(n))
+	case json.Number:
+		i, err := strconv.Atoi(n.String())
+		return i, err == nil
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestMessageMetadata(t *testing.T) {
+	plugin := getTestPlugin()
+	plugin.Config.Metadata = "team=payments, repo=spoofed"
+
+	metadata, err := plugin.messageMetadata()
+	assert.NilError(t, err)
+	assert.Equal(t, metadata.EventType, DefaultMetadataEventType)
+	assert.DeepEqual(t, metadata.EventPayload, map[string]interface{}{
+		"team":         "payments",
+		"repo":         "octocat/hello-world",
+		"build_number": 1,
+		"commit":       "7fd1a60b01f91b314f59955a4e4d4e80d8edf11d",
+		"branch":       "master",
+		"event":        "push",
+		"status":       "success",
+		"link":         "http://github.com/octocat/hello-world",
+		"tag":          "1.0.0",
+	})
+
+	plugin.Config.MetadataEventType = "deploy_finished"
+	metadata, err = plugin.messageMetadata()
+	assert.NilError(t, err)
+	assert.Equal(t, metadata.EventType, "deploy_finished")
+
+	plugin.Config.Metadata = "team"
+	_, err = plugin.messageMetadata()
+	assert.ErrorContains(t, err, "invalid metadata")
+}
+
+func TestIsBuildMessage(t *testing.T) {
+	metadata := func(number interface{}) slack.SlackMetadata {
+		return slack.SlackMetadata{EventPayload: map[string]interface{}{
+			"repo":         "octocat/hello-world",
+			"build_number": number,
+		}}
+	}
+
+	// Decoded JSON numbers are float64, which fmt prints as 1.234567e+06
+	assert.Assert(t, isBuildMessage(metadata(float64(1234567)), "octocat/hello-world", 1234567))
+	assert.Assert(t, isBuildMessage(metadata(json.Number("1234567")), "octocat/hello-world", 1234567))
+	assert.Assert(t, isBuildMessage(metadata("1234567"), "octocat/hello-world", 1234567))
+	assert.Assert(t, isBuildMessage(metadata(42), "octocat/hello-world", 42))
+
+	assert.Assert(t, !isBuildMessage(metadata(float64(1234567)), "octocat/hello-world", 1234568))
+	assert.Assert(t, !isBuildMessage(metadata(float64(1.5)), "octocat/hello-world", 1))
+	assert.Assert(t, !isBuildMessage(metadata(float64(1234567)), "octocat/spoon-knife", 1234567))
+	assert.Assert(t, !isBuildMessage(metadata(nil), "octocat/hello-world", 0))
+}
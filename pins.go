@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// pinMarkerKey in the metadata payload marks a message the plugin pinned,
+// so that pins made by people are never touched.
+const pinMarkerKey = "drone_slack_pin"
+
+// isTagBuild reports whether the build was triggered by a tag.
+func (b Build) isTagBuild() bool {
+	return b.Event == "tag" && b.Tag != ""
+}
+
+// pinRelease pins the announcement at channelID/ts and unpins the previous
+// announcements the plugin pinned for the same repository.
+func (p Plugin) pinRelease(channelID, ts string) error {
+	api := p.slackClient()
+	return pinRelease(api, p.report, channelID, ts, p.Repo.fullName())
+}
+
+func pinRelease(api *slack.Client, report *Report, channelID, ts, repo string) error {
+	start := time.Now()
+	err := api.AddPin(channelID, slack.NewRefToMessage(channelID, ts))
+	if err != nil && !isSlackError(err, "already_pinned") {
+		report.addPin(PinRecord{Timing: timingSince(start), Action: "pin", Channel: channelID, Timestamp: ts, Error: err.Error()})
+		return fmt.Errorf("failed to pin message %s: %w", ts, err)
+	}
+	report.addPin(PinRecord{Timing: timingSince(start), Action: "pin", Channel: channelID, Timestamp: ts})
+	log.Printf("Pinned release announcement %s", ts)
+
+	items, _, err := api.ListPins(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to list pins: %w", err)
+	}
+
+	for _, item := range items {
+		// Only bots can attach metadata, so anything else was pinned by a person
+		if item.Type != slack.TYPE_MESSAGE || item.Message == nil || item.Message.BotID == "" {
+			continue
+		}
+		pinnedTs := item.Message.Timestamp
+		if pinnedTs == ts {
+			continue
+		}
+
+		own, err := isPluginPin(api, channelID, pinnedTs, repo)
+		if err != nil {
+			return err
+		}
+		if !own {
+			continue
+		}
+
+		start := time.Now()
+		err = api.RemovePin(channelID, slack.NewRefToMessage(channelID, pinnedTs))
+		if err != nil && !isSlackError(err, "no_pin") {
+			report.addPin(PinRecord{Timing: timingSince(start), Action: "unpin", Channel: channelID, Timestamp: pinnedTs, Error: err.Error()})
+			return fmt.Errorf("failed to unpin message %s: %w", pinnedTs, err)
+		}
+		report.addPin(PinRecord{Timing: timingSince(start), Action: "unpin", Channel: channelID, Timestamp: pinnedTs})
+		log.Printf("Unpinned previous release announcement %s", pinnedTs)
+	}
+
+	return nil
+}
+
+// isPluginPin fetches the message at ts with its metadata, which pins.list
+// doesn't return, and checks for the pin marker of the repository.
+func isPluginPin(api *slack.Client, channelID, ts, repo string) (bool, error) {
+	history, err := api.GetConversationHistory(&slack.GetConversationHistoryParameters{
+		ChannelID:          channelID,
+		Latest:             ts,
+		Inclusive:          true,
+		Limit:              1,
+		IncludeAllMetadata: true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to read pinned message %s: %w", ts, err)
+	}
+
+	for _, msg := range history.Messages {
+		if msg.Timestamp != ts {
+			continue
+		}
+		payload := msg.Metadata.EventPayload
+		return payload[pinMarkerKey] == true && payload[metadataRepo] == repo, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestPinRelease(t *testing.T) {
+	history := map[string]string{
+		// Previous release pinned by the plugin
+		"100.000001": `{"ts":"100.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"drone_slack_pin":true,"repo":"octocat/hello-world","tag":"0.9.0"}}}`,
+		// Release of another repository sharing the channel
+		"100.000002": `{"ts":"100.000002","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"drone_slack_pin":true,"repo":"octocat/other","tag":"2.0.0"}}}`,
+		// Bot message pinned by a person
+		"100.000003": `{"ts":"100.000003","bot_id":"B1"}`,
+	}
+
+	var removed []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/pins.add":
+			assert.Equal(t, r.PostForm.Get("timestamp"), "200.000001")
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "/pins.list":
+			_, _ = w.Write([]byte(`{"ok":true,"items":[
+				{"type":"message","channel":"C123","message":{"ts":"200.000001","bot_id":"B1"}},
+				{"type":"message","channel":"C123","message":{"ts":"100.000001","bot_id":"B1"}},
+				{"type":"message","channel":"C123","message":{"ts":"100.000002","bot_id":"B1"}},
+				{"type":"message","channel":"C123","message":{"ts":"100.000003","bot_id":"B1"}},
+				{"type":"message","channel":"C123","message":{"ts":"100.000004","user":"U1"}}
+			]}`))
+		case "/conversations.history":
+			assert.Equal(t, r.PostForm.Get("include_all_metadata"), "1")
+			msg, ok := history[r.PostForm.Get("latest")]
+			assert.Assert(t, ok, "unexpected history lookup of %s", r.PostForm.Get("latest"))
+			_, _ = w.Write([]byte(`{"ok":true,"messages":[` + msg + `]}`))
+		case "/pins.remove":
+			removed = append(removed, r.PostForm.Get("timestamp"))
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		default:
+			t.Errorf("unexpected call to %s", r.URL.Path)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	report := newReport()
+	api := slack.New("test-access-token", slack.OptionAPIURL(server.URL+"/"))
+	err := pinRelease(api, report, "C123", "200.000001", "octocat/hello-world")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, removed, []string{"100.000001"})
+	assert.Equal(t, len(report.Pins), 2)
+	assert.Equal(t, report.Pins[0].Action, "pin")
+	assert.Equal(t, report.Pins[1].Action, "unpin")
+}
+
+func TestIsTagBuild(t *testing.T) {
+	assert.Assert(t, Build{Event: "tag", Tag: "v1.0.0"}.isTagBuild())
+	assert.Assert(t, !Build{Event: "push", Tag: "v1.0.0"}.isTagBuild())
+	assert.Assert(t, !Build{Event: "tag"}.isTagBuild())
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,6 +24,7 @@ type (
 	Repo struct {
 		Owner string
 		Name  string
+		Link  string
 	}
 
 	BlockSet struct {
@@ -44,6 +47,23 @@ type (
 		Link     string
 		Started  int64
 		Created  int64
+		Finished int64
+		// Branches of pull requests and promotions
+		SourceBranch string
+		TargetBranch string
+		CommitLink   string
+		// Commit before a push, the start of the changes of the build
+		Before string
+		// Steps and stages of the build that failed
+		FailedSteps  []string
+		FailedStages []string
+		// Versions derived from the tag by Drone
+		Semver Semver
+		Calver string
+		// Status of the previous build and the fixed/broken pseudo-status,
+		// only set when notifying on status changes
+		PreviousStatus string
+		Change         string
 	}
 
 	Author struct {
@@ -81,16 +101,134 @@ type (
 		FileName       string
 		InitialComment string
 		Title          string
-		FailOnError    bool
+		// How failed Slack operations affect the build
+		ErrorPolicy ErrorPolicy
 		// Get Slack ID of the user by email
 		SlackIdOf string
 		// Git path to get list of committer emails
 		CommitterListGitPath string
-		CommitterSlackId     bool
+		// Git repository of the build for releases, changelogs and changes
+		GitPath          string
+		CommitterSlackId bool
+		// How committers are notified: a direct message or an ephemeral message in Channel
+		CommitterDelivery string
+		// Path of the JSON report summarising every action taken
+		OutputFile string
+		// Schedule the message instead of posting it right away
+		SendAt string
+		// Cancel a message scheduled by a previous step
+		CancelScheduledID string
+		// Channel ID the message to cancel was scheduled in
+		ScheduledChannel string
+		// Timestamp of an existing message to act on
+		MessageTs string
+		// React to MessageTs instead of posting a message
+		Reactions      bool
+		Reaction       string
+		ReactionEmojis string
+		// Pin tag build announcements and unpin the previous one
+		PinReleases bool
+		// Metadata attached to every posted message
+		MetadataEventType string
+		Metadata          string
+		// Update or reply to the earlier message for the same build
+		FindBuildMessage string
+		LookBack         time.Duration
+		// Reply in the thread of the earlier message for the same build,
+		// like FindBuildMessage set to thread
+		ThreadBuild bool
+		// Only notify when the build breaks or is fixed
+		NotifyOnChange bool
+		StateFile      string
+		// Skip or fold repeated notifications within a window
+		SuppressWindow time.Duration
+		SuppressKey    string
+		SuppressMode   string
+		// Defer, quieten or drop notifications during quiet hours
+		QuietHours          string
+		QuietTimezone       string
+		QuietHolidays       string
+		QuietAction         string
+		QuietActions        string
+		QuietBypassBranches string
+		// Base URL of the Slack Web API
+		APIURL string
+		// Chat service the webhook belongs to
+		Transport string
+		// Slack user IDs to usernames on other chat services
+		UserMap string
+		// HTTP client settings for Slack, webhooks and templates
+		Proxy       string
+		CACert      string
+		HTTPTimeout time.Duration
+		UserAgent   string
+		// Fetching of remote templates
+		TemplateHeaders  string
+		TemplateSHA256   string
+		TemplateMaxSize  int64
+		TemplateTimeout  time.Duration
+		TemplateCacheDir string
+		// Fail when a template, fallback or custom block looks like a
+		// missing file instead of using it literally
+		TemplateStrict bool
+		// Add the commits since the previous tag to tag build messages
+		Changelog      bool
+		ChangelogLimit int
+		// Summarise the changed files per directory
+		Changes       bool
+		ChangesBlock  bool
+		ChangesGroups string
+		ChangesDepth  int
+		ChangesLimit  int
+		// Comma separated globs of JUnit XML reports in the workspace
+		JUnitReports string
+		JUnitLimit   int
+		// Comma separated globs of go test -json output and coverage
+		// profiles in the workspace, and the coverage to compare with
+		GoTestReports    string
+		GoTestLimit      int
+		CoverageProfiles string
+		CoverageBaseline string
+		CoverageLimit    int
 	}
 
 	Job struct {
-		Started int64
+		Started  int64
+		Finished int64
+	}
+
+	// Stage is the pipeline the plugin runs in.
+	Stage struct {
+		Name      string
+		Number    int
+		Kind      string
+		Type      string
+		Status    string
+		Started   int64
+		Finished  int64
+		Machine   string
+		OS        string
+		Arch      string
+		Variant   string
+		DependsOn []string
+	}
+
+	// Step is the step the plugin runs in.
+	Step struct {
+		Name   string
+		Number int
+	}
+
+	// Semver is the tag parsed as a semantic version by Drone.
+	Semver struct {
+		Version    string
+		Short      string
+		Major      string
+		Minor      string
+		Patch      string
+		Prerelease string
+		Build      string
+		Error      string
 	}
 
 	Plugin struct {
@@ -98,9 +236,34 @@ type (
 		Build  Build
 		Config Config
 		Job    Job
+		Stage  Stage
+		Step   Step
+		// Every DRONE_* environment variable
+		Env map[string]string
+		// Tag of tag builds as a semantic version
+		Release *Release
+		// Commits since the previous release
+		Changelog *Changelog
+		// Files changed by the build per directory
+		Changes *ChangeSummary
+		// Results of the JUnit reports of the build
+		Tests *TestReport
+		// Results of the go test -json output of the build
+		GoTest *GoTestReport
+		// Coverage of the Go coverage profiles of the build
+		Coverage *Coverage
+
+		report      *Report
+		client      *http.Client
+		suppressKey string
 	}
 )
 
+const (
+	CommitterDeliveryDM        = "dm"
+	CommitterDeliveryEphemeral = "ephemeral"
+)
+
 func (a Author) String() string {
 	return a.Username
 }
@@ -120,22 +283,103 @@ func (m Message) String() string {
 }
 
 func (p Plugin) Exec() error {
+	client, err := newHTTPClient(p.Config)
+	if err != nil {
+		return err
+	}
+	p.client = client
+
+	if p.Config.OutputFile == "" {
+		return p.exec()
+	}
+
+	p.report = newReport()
+	err = p.exec()
+	if werr := p.report.write(p.Config.OutputFile, err); werr != nil {
+		log.Println("Failed to write output file: ", werr)
+		if err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func (p Plugin) exec() error {
 	var blocks []slack.Block
 	var channel string
 	var text string
 	var fallbackText string
 
 	if p.Config.FilePath != "" {
-		return p.UploadFile()
+		p.report.setMode(modeUploadFile)
+		return p.applyErrorPolicy(opUploadFile, p.UploadFile())
 	}
 
 	if p.Config.SlackIdOf != "" {
-		return GetSlackIdFromEmail(&p)
+		p.report.setMode(modeEmailLookup)
+		return p.applyErrorPolicy(opEmailLookup, GetSlackIdFromEmail(&p))
+	}
+
+	if p.Config.CancelScheduledID != "" {
+		p.report.setMode(modeCancelSchedule)
+		return p.applyErrorPolicy(opCancelSchedule, p.CancelScheduledMessage())
+	}
+
+	if p.Config.Reactions {
+		p.report.setMode(modeReactions)
+		return p.applyErrorPolicy(opReactions, p.Reactions())
 	}
 
 	if p.Config.CommitterSlackId && p.Config.Channel == "" {
-		_, err := GetSlackIdsOfCommitters(&p, GetChangesetAuthorsList, getSlackUserIDByEmail)
-		return err
+		p.report.setMode(modeCommitterLookup)
+		_, err := GetSlackIdsOfCommitters(&p, GetChangesetAuthorsList, p.getSlackUserIDByEmail)
+		return p.applyErrorPolicy(opCommitterLookup, err)
+	}
+
+	// Set before anything can skip the notification, the branches below
+	// narrow it down
+	p.report.setMode(p.deliveryMode())
+	if p.Config.SendAt != "" && p.deliveryMode() == modeWebhook {
+		return errors.New("send at needs an access token, messages can't be scheduled through a webhook")
+	}
+
+	if p.Config.NotifyOnChange {
+		notify, err := p.detectStatusChange()
+		if err := p.applyErrorPolicy(opStatusChange, err); err != nil {
+			return err
+		}
+		if !notify {
+			return nil
+		}
+	}
+
+	if p.Config.SuppressWindow > 0 {
+		suppressed, err := p.checkSuppression()
+		if err := p.applyErrorPolicy(opSuppress, err); err != nil {
+			return err
+		}
+		if suppressed {
+			return nil
+		}
+	}
+
+	quietAction := QuietSend
+	var deferUntil time.Time
+	if p.Config.QuietHours != "" || p.Config.QuietHolidays != "" {
+		var err error
+		quietAction, deferUntil, err = p.quietAction(time.Now())
+		if err != nil {
+			return err
+		}
+		if quietAction == QuietDefer && p.Config.AccessToken == "" {
+			log.Println("Messages can't be deferred through a webhook, sending without mentions instead")
+			quietAction = QuietNoMention
+		}
+		if quietAction == QuietDrop {
+			log.Println("Quiet hours, dropping notification")
+			p.report.skip("quiet hours")
+			return nil
+		}
 	}
 
 	// Determine the channel
@@ -145,6 +389,45 @@ func (p Plugin) Exec() error {
 		channel = prepend("#", p.Config.Channel)
 	}
 
+	if p.usesRelease() {
+		p.Release = p.release()
+	}
+	if p.Config.Changelog && p.Build.Tag != "" {
+		changelog, err := p.changelog()
+		if err != nil {
+			log.Println("Could not collect the changelog: ", err)
+		}
+		p.Changelog = changelog
+	}
+	if p.Config.Changes || p.Config.ChangesBlock {
+		changes, err := p.changes()
+		if err != nil {
+			log.Println("Could not summarise the changed files: ", err)
+		}
+		p.Changes = changes
+	}
+	if p.Config.JUnitReports != "" {
+		tests, err := p.testReport()
+		if err != nil {
+			log.Println("Could not read the test reports: ", err)
+		}
+		p.Tests = tests
+	}
+	if p.Config.GoTestReports != "" {
+		goTest, err := p.goTestReport()
+		if err != nil {
+			log.Println("Could not read the go test reports: ", err)
+		}
+		p.GoTest = goTest
+	}
+	if p.Config.CoverageProfiles != "" {
+		coverage, err := p.coverage()
+		if err != nil {
+			log.Println("Could not read the coverage profiles: ", err)
+		}
+		p.Coverage = coverage
+	}
+
 	// Determine the message and fallback
 	if p.Config.Template != "" {
 		var err error
@@ -159,7 +442,7 @@ func (p Plugin) Exec() error {
 	}
 
 	// Add mentions to the message
-	if p.Config.Mentions != "" {
+	if p.Config.Mentions != "" && quietAction != QuietNoMention {
 		var mentionUserIDs = strings.Split(p.Config.Mentions, ",")
 		mentions := make([]string, len(mentionUserIDs))
 		for i, id := range mentionUserIDs {
@@ -186,6 +469,10 @@ func (p Plugin) Exec() error {
 			filePath = "templates/success_tag_deploy.json"
 		case "basic_on_hold_1":
 			filePath = "templates/basic_on_hold.json"
+		case "release_1":
+			filePath = "templates/release.json"
+		case "test_report_1":
+			filePath = "templates/test_report.json"
 		default:
 			return fmt.Errorf("invalid template name: %s", p.Config.CustomTemplate)
 		}
@@ -235,6 +522,8 @@ func (p Plugin) Exec() error {
 				block = new(slack.HeaderBlock)
 			case "actions":
 				block = new(slack.ActionBlock)
+			case "context":
+				block = new(slack.ContextBlock)
 			default:
 				return fmt.Errorf("unknown block type: %s", blockType.Type)
 			}
@@ -268,7 +557,15 @@ func (p Plugin) Exec() error {
 	// Parse custom blocks if they exist
 	if p.Config.CustomBlock != "" {
 		var blockSet BlockSet
-		err := json.Unmarshal([]byte(p.Config.CustomBlock), &blockSet)
+		loader, err := p.templateLoader()
+		if err != nil {
+			return err
+		}
+		customBlock, err := loader.contents(p.Config.CustomBlock)
+		if err != nil {
+			return fmt.Errorf("could not read custom block: %w", err)
+		}
+		err = json.Unmarshal([]byte(customBlock), &blockSet)
 		if err != nil {
 			return fmt.Errorf("could not unmarshal custom block: %w", err)
 		}
@@ -282,14 +579,46 @@ func (p Plugin) Exec() error {
 		}
 	}
 
-	// If access token is provided, use it
-	if p.Config.AccessToken != "" {
-		slackApi := slack.New(p.Config.AccessToken)
-		_, err := slackApi.AuthTest()
-		if err != nil {
-			return fmt.Errorf("failed to authenticate using access token: %w", err)
+	// Add the changelog, as a section when the message is made of blocks
+	if p.Changelog != nil {
+		if len(blocks) > 0 {
+			blocks = insertBeforeActions(blocks, p.Changelog.block())
+		} else {
+			text = text + "\n" + p.Changelog.Text
 		}
+	}
+	if p.Changes != nil && p.Config.ChangesBlock {
+		if len(blocks) > 0 {
+			blocks = insertBeforeActions(blocks, p.Changes.block())
+		} else {
+			text = text + "\n" + p.Changes.Text
+		}
+	}
+	if p.Tests != nil {
+		if len(blocks) > 0 {
+			for _, block := range p.Tests.blocks(p.junitLimit()) {
+				blocks = insertBeforeActions(blocks, block)
+			}
+		} else {
+			text = text + "\n" + p.Tests.Text
+		}
+	}
+
+	if quietAction == QuietNoMention {
+		log.Println("Quiet hours, sending without mentions")
+		text = stripMentions(text)
+		fallbackText = stripMentions(fallbackText)
+		if len(blocks) > 0 {
+			var err error
+			if blocks, err = stripBlockMentions(blocks); err != nil {
+				return err
+			}
+		}
+	}
 
+	// If access token is provided, use it. Other services only have webhooks.
+	if p.Config.AccessToken != "" && (p.Config.Transport == "" || p.Config.Transport == TransportSlack) {
+		p.report.setMode(modeMessage)
 		options := []slack.MsgOption{}
 		if len(blocks) > 0 {
 			options = append(options, slack.MsgOptionBlocks(blocks...))
@@ -297,58 +626,193 @@ func (p Plugin) Exec() error {
 			options = append(options, slack.MsgOptionText(text, false))
 		}
 
-		_, _, err = slackApi.PostMessage(channel, options...)
+		if p.Config.SendAt != "" {
+			p.report.setMode(modeSchedule)
+			postAt, err := parseSendAt(p.Config.SendAt, time.Now())
+			if err != nil {
+				return err
+			}
+			return p.applyErrorPolicy(opScheduleMessage, p.scheduleMessage(channel, options, postAt))
+		}
+
+		if quietAction == QuietDefer {
+			log.Printf("Quiet hours, deferring notification until %s", deferUntil.Format(time.RFC3339))
+			p.report.setMode(modeSchedule)
+			return p.applyErrorPolicy(opScheduleMessage, p.scheduleMessage(channel, options, deferUntil))
+		}
+
+		metadata, err := p.messageMetadata()
 		if err != nil {
-			return fmt.Errorf("failed to post message using access token: %w", err)
+			return err
+		}
+
+		pinning := p.Config.PinReleases && p.Build.isTagBuild()
+		if pinning {
+			metadata.EventPayload[pinMarkerKey] = true
 		}
 
-		if p.Config.CommitterSlackId {
-			err := p.sendDirectMessageToCommitters(options)
+		postOptions := append([]slack.MsgOption{}, options...)
+		postOptions = append(postOptions, slack.MsgOptionMetadata(metadata))
+
+		var buildChannelID, buildTs string
+		findMode := p.findBuildMessageMode()
+		if findMode != "" {
+			buildChannelID, buildTs, err = p.findBuildMessage()
+			if err := p.applyErrorPolicy(opFindMessage, err); err != nil {
+				return err
+			}
+		}
+
+		var channelID, ts string
+		if buildTs != "" && findMode == FindBuildMessageUpdate {
+			channelID, ts, err = p.updateMessage(buildChannelID, buildTs, postOptions)
+			if err := p.applyErrorPolicy(opUpdateMessage, err); err != nil {
+				return err
+			}
+		} else {
+			if buildTs != "" {
+				postOptions = append(postOptions, slack.MsgOptionTS(buildTs))
+			}
+			channelID, ts, err = p.postMessage(channel, postOptions)
+			if err := p.applyErrorPolicy(opPostMessage, err); err != nil {
+				return err
+			}
+		}
+
+		// A failed post only warns, so check it was sent
+		if ts != "" {
+			if err := p.recordSuppression(channelID, ts); err != nil {
+				log.Println("Failed to record notification for duplicate suppression: ", err)
+			}
+			if err := p.recordStatus(); err != nil {
+				log.Println("Failed to record build status: ", err)
+			}
+		}
+
+		if pinning && ts != "" {
+			if err := p.applyErrorPolicy(opPin, p.pinRelease(channelID, ts)); err != nil {
+				return err
+			}
+		}
+
+		if p.Config.CommitterSlackId && quietAction == QuietNoMention && p.Config.CommitterDelivery != CommitterDeliveryEphemeral {
+			log.Println("Quiet hours, not sending direct messages to committers")
+		} else if p.Config.CommitterSlackId {
+			lookupErr, err := p.sendDirectMessageToCommitters(channel, options, metadata)
 			if err != nil {
-				return fmt.Errorf("failed to send direct message to committers: %w", err)
+				err = fmt.Errorf("failed to send direct message to committers: %w", err)
 			}
+			// The committers that didn't resolve fail through their own policy
+			return errors.Join(
+				p.applyErrorPolicy(opCommitterLookup, lookupErr),
+				p.applyErrorPolicy(opCommitterMessage, err),
+			)
 		}
 
 		return nil
 	}
 
-	// Build the attachment
-	attachment := slack.Attachment{
-		Color:      colorText,
-		ImageURL:   p.Config.ImageURL,
-		MarkdownIn: []string{"text", "fallback"},
-		Text:       text,
-		Fallback:   fallbackText,
+	transport, err := p.transport()
+	if err != nil {
+		return err
 	}
 
-	// Build the payload
-	payload := slack.WebhookMessage{
-		Username:    p.Config.Username,
-		Attachments: []slack.Attachment{attachment},
-		IconURL:     p.Config.IconURL,
-		IconEmoji:   p.Config.IconEmoji,
-		Channel:     channel,
+	// Post the message with the webhook
+	p.report.setMode(modeWebhook)
+	start := time.Now()
+	err = transport.Send(Notification{
+		Channel:   channel,
+		Username:  p.Config.Username,
+		IconURL:   p.Config.IconURL,
+		IconEmoji: p.Config.IconEmoji,
+		Text:      text,
+		Fallback:  fallbackText,
+		Color:     colorText,
+		ImageURL:  p.Config.ImageURL,
+		Blocks:    blocks,
+	})
+	p.report.addMessage(MessageRecord{
+		Timing:  timingSince(start),
+		Channel: channel,
+		Error:   errorString(err),
+	})
+	if err == nil {
+		if err := p.recordSuppression(channel, ""); err != nil {
+			log.Println("Failed to record notification for duplicate suppression: ", err)
+		}
+		if err := p.recordStatus(); err != nil {
+			log.Println("Failed to record build status: ", err)
+		}
 	}
+	return p.applyErrorPolicy(opWebhook, err)
+}
 
-	// Add custom blocks to the payload if they exist
-	if len(blocks) > 0 {
-		payload.Blocks = &slack.Blocks{
-			BlockSet: blocks,
-		}
+// deliveryMode is the report mode of the notification: a message with the
+// access token, or else the webhook.
+func (p Plugin) deliveryMode() string {
+	if p.Config.AccessToken != "" && (p.Config.Transport == "" || p.Config.Transport == TransportSlack) {
+		return modeMessage
 	}
+	return modeWebhook
+}
 
-	// Post the message with the webhook
-	return slack.PostWebhook(p.Config.Webhook, &payload)
+func (p Plugin) postMessage(channel string, options []slack.MsgOption) (string, string, error) {
+	start := time.Now()
+	slackApi := p.slackClient()
+	_, err := slackApi.AuthTest()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to authenticate using access token: %w", err)
+	}
+
+	channelID, ts, err := slackApi.PostMessage(channel, options...)
+	if err != nil {
+		p.report.addMessage(MessageRecord{
+			Timing:  timingSince(start),
+			Channel: channel,
+			Error:   err.Error(),
+		})
+		return "", "", fmt.Errorf("failed to post message using access token: %w", err)
+	}
+
+	p.report.addMessage(MessageRecord{
+		Timing:    timingSince(start),
+		Channel:   channelID,
+		Timestamp: ts,
+		Permalink: p.permalink(slackApi, channelID, ts),
+	})
+	return channelID, ts, nil
+}
+
+// permalink looks up the link to a posted message, but only when a report is
+// being collected as it costs an extra API call.
+func (p Plugin) permalink(api *slack.Client, channelID, ts string) string {
+	if p.report == nil {
+		return ""
+	}
+
+	link, err := api.GetPermalink(&slack.PermalinkParameters{Channel: channelID, Ts: ts})
+	if err != nil {
+		log.Printf("Failed to get permalink for message %s: %v", ts, err)
+		return ""
+	}
+	return link
 }
 
 func (p Plugin) UploadFile() error {
 
 	p.Config.FilePath = strings.TrimSpace(p.Config.FilePath)
 
-	api := slack.New(p.Config.AccessToken)
+	api := p.slackClient()
+	start := time.Now()
 	fileSize, err := GetFileSize(p.Config.FilePath)
 	if err != nil {
-		log.Printf("Error getting file size: %s\n", err.Error())
+		log.Printf("Error getting file size: %s", err)
+		p.report.addUpload(UploadRecord{
+			Timing:  timingSince(start),
+			Channel: p.Config.Channel,
+			Path:    p.Config.FilePath,
+			Error:   err.Error(),
+		})
 		return err
 	}
 
@@ -367,36 +831,29 @@ func (p Plugin) UploadFile() error {
 	}
 
 	slackSummary, err := api.UploadFileV2(params)
-
-	if !p.Config.FailOnError && slackSummary == nil {
-		if err != nil {
-			log.Println("Bad Api ret val, upload file failed but passing build as PLUGIN_FAIL_ON_ERROR is false")
-		}
-		return nil
-	} else if p.Config.FailOnError && slackSummary == nil {
-		log.Println("Bad ret val,  Failed to upload file, failing build")
-		_ = p.WriteFileUploadResult("", "", err)
-		return fmt.Errorf("Bad ret val, Failed to upload file %s ", p.Config.FilePath)
+	if err == nil && slackSummary == nil {
+		err = fmt.Errorf("bad return value from upload api")
 	}
-
-	if !p.Config.FailOnError && err != nil {
-		if err != nil {
-			log.Println("Unable to upload file but passing build PLUGIN_FAIL_ON_ERROR is false")
-		}
-		return nil
-	} else if p.Config.FailOnError && err != nil {
-		log.Println("Upload API Failed to upload file, failing build")
+	upload := UploadRecord{
+		Timing:  timingSince(start),
+		Channel: p.Config.Channel,
+		Path:    p.Config.FilePath,
+		Error:   errorString(err),
+	}
+	if slackSummary != nil {
+		upload.FileID = slackSummary.ID
+		upload.Title = slackSummary.Title
+	}
+	p.report.addUpload(upload)
+	if err != nil {
+		log.Println("Upload API failed to upload file: ", err)
 		_ = p.WriteFileUploadResult("", "", err)
-		return fmt.Errorf("Failed to upload file %s ", p.Config.FilePath)
+		return fmt.Errorf("failed to upload file %s: %w", p.Config.FilePath, err)
 	}
 
-	err = p.WriteFileUploadResult(slackSummary.ID, slackSummary.Title, err)
-	if !p.Config.FailOnError {
-		if err != nil {
-			log.Println("Unable to Write output env var results for file upload " +
-				"but passing build PLUGIN_FAIL_ON_ERROR is false")
-		}
-		return nil
+	err = p.WriteFileUploadResult(slackSummary.ID, slackSummary.Title, nil)
+	if err != nil {
+		log.Println("Unable to write output env var results for file upload: ", err)
 	}
 
 	return nil
@@ -432,7 +889,13 @@ func (p Plugin) WriteFileUploadResult(slackFileId, title string, err error) erro
 }
 
 func WriteEnvToOutputFile(key, value string) error {
-	outputFile, err := os.OpenFile(os.Getenv("DRONE_OUTPUT"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	outputPath := os.Getenv("DRONE_OUTPUT")
+	if outputPath == "" {
+		// Not running inside a pipeline that collects step outputs
+		return nil
+	}
+
+	outputFile, err := os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open output file: %w", err)
 	}
@@ -456,7 +919,11 @@ func GetFileSize(filePath string) (int, error) {
 }
 
 func templateMessage(t string, plugin Plugin) (string, error) {
-	c, err := contents(t)
+	loader, err := plugin.templateLoader()
+	if err != nil {
+		return "", err
+	}
+	c, err := loader.contents(t)
 	if err != nil {
 		return "", fmt.Errorf("could not read template: %w", err)
 	}
@@ -488,10 +955,15 @@ func fallback(repo Repo, build Build) string {
 }
 
 func color(build Build) string {
-	switch build.Status {
-	case "success":
+	status := build.Status
+	if build.Change != "" {
+		status = build.Change
+	}
+
+	switch status {
+	case "success", StatusFixed:
 		return "good"
-	case "failure", "error", "killed":
+	case "failure", "error", "killed", StatusBroken:
 		return "danger"
 	default:
 		return "warning"
@@ -514,44 +986,83 @@ func GetSlackIdsOfCommitters(p *Plugin,
 		p.Config.CommitterListGitPath = os.Getenv("DRONE_WORKSPACE")
 	}
 
+	start := time.Now()
 	emails, err := getAuthorsListFunc(p.Config.CommitterListGitPath)
 	if err != nil {
 		log.Println("Failed to get git emails: ", err)
+		p.report.addLookup(LookupRecord{Timing: timingSince(start), Kind: "committers", Error: err.Error()})
 		return []string{}, fmt.Errorf("failed to get git emails: %w", err)
 	}
 
 	slackUserIdList, err := getSlackUserIDByEmailFunc(p.Config.AccessToken, strings.Join(emails, ","))
+	p.report.addLookup(LookupRecord{
+		Timing: timingSince(start),
+		Kind:   "committers",
+		Emails: emails,
+		IDs:    slackUserIdList,
+		Error:  errorString(err),
+	})
 	if err != nil {
 		log.Println("Failed to get Slack ID by email: ", err)
-		return []string{}, fmt.Errorf("failed to get Slack ID by email: %w", err)
+		err = fmt.Errorf("failed to get Slack ID by email: %w", err)
+		// Committers without a Slack user don't cancel the others
+		if !isPartialLookup(err) {
+			return []string{}, err
+		}
 	}
 
 	jsonStr := strings.Join(slackUserIdList, ",")
-	err = WriteEnvToOutputFile("COMMITTERS_SLACK_IDS", jsonStr)
-	if err != nil {
-		log.Println("Failed to write git emails to output file: ", err)
-		return []string{}, fmt.Errorf("failed to write git emails to output file: %w", err)
+	if werr := WriteEnvToOutputFile("COMMITTERS_SLACK_IDS", jsonStr); werr != nil {
+		log.Println("Failed to write git emails to output file: ", werr)
+		return []string{}, fmt.Errorf("failed to write git emails to output file: %w", werr)
 	}
 
-	return slackUserIdList, nil
+	return slackUserIdList, err
 }
 
 func GetSlackIdFromEmail(p *Plugin) error {
-	slackIdList, err := getSlackUserIDByEmail(p.Config.AccessToken, p.Config.SlackIdOf)
+	start := time.Now()
+	slackIdList, err := p.getSlackUserIDByEmail(p.Config.AccessToken, p.Config.SlackIdOf)
+	p.report.addLookup(LookupRecord{
+		Timing: timingSince(start),
+		Kind:   "email",
+		Emails: strings.Split(p.Config.SlackIdOf, ","),
+		IDs:    slackIdList,
+		Error:  errorString(err),
+	})
 	if err != nil {
 		log.Println("Failed to get Slack ID by email: ", err)
-		return fmt.Errorf("failed to get Slack ID by email: %w", err)
+		err = fmt.Errorf("failed to get Slack ID by email: %w", err)
+		if !isPartialLookup(err) {
+			return err
+		}
 	}
 
 	slackIdsCsvStr := strings.Join(slackIdList, ",")
-	err = WriteEnvToOutputFile("SLACK_ID_FROM_EMAIL", slackIdsCsvStr)
-	if err != nil {
-		return fmt.Errorf("failed to write Slack ID to output file: %w", err)
+	if werr := WriteEnvToOutputFile("SLACK_ID_FROM_EMAIL", slackIdsCsvStr); werr != nil {
+		return fmt.Errorf("failed to write Slack ID to output file: %w", werr)
 	}
-	return nil
+	return err
+}
+
+// emailLookupError lists the emails that have no Slack user. The IDs of the
+// other emails are still returned with it.
+type emailLookupError struct {
+	Emails []string
 }
 
-func getSlackUserIDByEmail(accessToken, emailListStr string) ([]string, error) {
+func (e *emailLookupError) Error() string {
+	return fmt.Sprintf("failed to fetch Slack IDs for emails: %s", strings.Join(e.Emails, ","))
+}
+
+// isPartialLookup reports whether err only lists emails that didn't
+// resolve, so the IDs returned with it can be used.
+func isPartialLookup(err error) bool {
+	var lookupErr *emailLookupError
+	return errors.As(err, &lookupErr)
+}
+
+func (p Plugin) getSlackUserIDByEmail(accessToken, emailListStr string) ([]string, error) {
 
 	emailArray := []string{}
 	for _, email := range strings.Split(emailListStr, ",") {
@@ -562,14 +1073,14 @@ func getSlackUserIDByEmail(accessToken, emailListStr string) ([]string, error) {
 	}
 	slackIdsList := []string{}
 
+	api := p.slackClientFor(accessToken)
+	if api == nil {
+		log.Println("Failed to create Slack client")
+		return nil, fmt.Errorf("failed to create Slack client")
+	}
+
 	var failedEmails []string
 	for _, email := range emailArray {
-		api := slack.New(accessToken)
-		if api == nil {
-			log.Println("Failed to create Slack client")
-			return emailArray, fmt.Errorf("failed to create Slack client")
-		}
-
 		user, err := api.GetUserByEmail(email)
 		if err != nil {
 			log.Printf("Failed to fetch Slack ID for email %s: %v", email, err)
@@ -583,46 +1094,88 @@ func getSlackUserIDByEmail(accessToken, emailListStr string) ([]string, error) {
 	}
 	if len(failedEmails) > 0 {
 		log.Printf("Failed to fetch Slack IDs for the following emails: %v", failedEmails)
+		return slackIdsList, &emailLookupError{Emails: failedEmails}
 	}
 
 	return slackIdsList, nil
 }
 
-func (p Plugin) sendDirectMessageToCommitters(options []slack.MsgOption) error {
-	slackUserIdList, err := GetSlackIdsOfCommitters(&p, GetChangesetAuthorsList, getSlackUserIDByEmail)
-	if err != nil {
-		log.Println("Failed to get Slack ID by email: ", err)
-		return fmt.Errorf("failed to get Slack ID by email: %w", err)
+// sendDirectMessageToCommitters messages every committer that resolves to a
+// Slack user. It returns the error of the lookup apart from the errors of the
+// messages.
+func (p Plugin) sendDirectMessageToCommitters(channel string, options []slack.MsgOption, metadata slack.SlackMetadata) (lookupErr, err error) {
+	slackUserIdList, lookupErr := GetSlackIdsOfCommitters(&p, GetChangesetAuthorsList, p.getSlackUserIDByEmail)
+	if lookupErr != nil && !isPartialLookup(lookupErr) {
+		return lookupErr, nil
 	}
+
+	dmOptions := append([]slack.MsgOption{}, options...)
+	dmOptions = append(dmOptions, slack.MsgOptionMetadata(metadata))
+
+	api := p.slackClient()
+	var errs []error
 	for _, slackUserId := range slackUserIdList {
-		err = sendDirectMessage(p.Config.AccessToken, slackUserId, options)
+		start := time.Now()
+		record := MessageRecord{User: slackUserId, Delivery: CommitterDeliveryDM}
+
+		if p.Config.CommitterDelivery == CommitterDeliveryEphemeral {
+			record.Delivery = CommitterDeliveryEphemeral
+			record.Channel = channel
+			// Ephemeral messages can't carry metadata
+			record.Timestamp, err = sendEphemeralMessage(api, channel, slackUserId, options)
+			if isSlackError(err, "user_not_in_channel") {
+				log.Printf("%s is not a member of %s, sending a direct message instead", slackUserId, channel)
+				record.Delivery = CommitterDeliveryDM
+				record.Channel, record.Timestamp, err = sendDirectMessage(api, slackUserId, dmOptions)
+			}
+		} else {
+			record.Channel, record.Timestamp, err = sendDirectMessage(api, slackUserId, dmOptions)
+		}
+
+		record.Timing = timingSince(start)
+		record.Error = errorString(err)
+		p.report.addDirectMessage(record)
 		if err != nil {
-			log.Println("Failed to send direct message: ", err)
+			log.Println("Failed to send message to committer: ", err)
+			errs = append(errs, err)
 			continue
 		}
 		log.Println("Message sent successfully for ", slackUserId)
 	}
-	return nil
+
+	return lookupErr, errors.Join(errs...)
 }
 
-func sendDirectMessage(botToken, userID string, options []slack.MsgOption) error {
+func sendEphemeralMessage(client *slack.Client, channel, userID string, options []slack.MsgOption) (string, error) {
+	ts, err := client.PostEphemeral(channel, userID, options...)
+	if err != nil {
+		return "", fmt.Errorf("failed to send ephemeral slack message to %s: %w", userID, err)
+	}
+
+	return ts, nil
+}
 
-	client := slack.New(botToken)
+// isSlackError reports whether err is the Slack API error with the given code.
+func isSlackError(err error, code string) bool {
+	var slackErr slack.SlackErrorResponse
+	return errors.As(err, &slackErr) && slackErr.Err == code
+}
 
+func sendDirectMessage(client *slack.Client, userID string, options []slack.MsgOption) (string, string, error) {
 	channel, _, _, err := client.OpenConversation(&slack.OpenConversationParameters{
 		ReturnIM: true,
 		Users:    []string{userID},
 	})
 	if err != nil {
-		log.Println("Failed to open conversation: ", err)
+		return "", "", fmt.Errorf("failed to open conversation with %s: %w", userID, err)
 	}
 
-	_, _, err = client.PostMessage(channel.ID, options...)
+	_, ts, err := client.PostMessage(channel.ID, options...)
 	if err != nil {
-		log.Printf("Failed to send direct slack message: %v", err)
+		return channel.ID, "", fmt.Errorf("failed to send direct slack message to %s: %w", userID, err)
 	}
 
-	return nil
+	return channel.ID, ts, nil
 }
 
 func GetChangesetAuthorsList(gitDir string) ([]string, error) {
@@ -7,9 +7,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/slack-go/slack"
 	"gotest.tools/v3/assert"
 )
 
@@ -21,18 +23,20 @@ func TestExec(t *testing.T) {
 		Config: getTestConfig(),
 	}
 
+	var got []string
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		out, _ := io.ReadAll(r.Body)
-		got := string(out)
-		want := `{"attachments":[{"color":"good","fallback":"Message Template Fallback:\nInitial commit\nmaster\nsuccess","text":"Message Template:\nInitial commit\n\nMessage body\nInitial commit\nMessage body","mrkdwn_in":["text","fallback"],"blocks":null}],"replace_original":false,"delete_original":false}`
-		assert.Equal(t, got, want)
+		got = append(got, string(out))
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(handler))
 	defer server.Close()
 
 	plugin.Config.Webhook = server.URL
-	_ = plugin.Exec()
+	assert.NilError(t, plugin.Exec())
+
+	want := `{"attachments":[{"color":"good","fallback":"Message Template Fallback:\nInitial commit\nmaster\nsuccess","text":"Message Template:\nInitial commit\n\nMessage body\nInitial commit\nMessage body","mrkdwn_in":["text","fallback"],"blocks":null}],"replace_original":false,"delete_original":false}`
+	assert.DeepEqual(t, got, []string{want})
 }
 
 func TestNewCommitMessage(t *testing.T) {
@@ -188,67 +192,68 @@ func getTestConfig() Config {
 }
 
 func TestFileUpload(t *testing.T) {
+	fake := newFakeSlack(t)
 
-	cfg := Config{
-		Channel:        os.Getenv("PLUGIN_CHANNEL"),
-		AccessToken:    os.Getenv("PLUGIN_ACCESS_TOKEN"),
-		FilePath:       os.Getenv("PLUGIN_FILE_PATH"),
-		FileName:       os.Getenv("PLUGIN_FILE_NAME"),
-		InitialComment: os.Getenv("PLUGIN_INITIAL_COMMENT"),
-		Title:          os.Getenv("PLUGIN_TITLE"),
-	}
+	path := filepath.Join(t.TempDir(), "coverage.html")
+	assert.NilError(t, os.WriteFile(path, []byte("<html></html>"), 0644))
 
 	plugin := Plugin{
-		Repo:   getTestRepo(),
-		Build:  getTestBuild(),
-		Job:    getTestJob(),
-		Config: cfg,
+		Repo:  getTestRepo(),
+		Build: getTestBuild(),
+		Job:   getTestJob(),
+		Config: Config{
+			Channel:        "CBUILDS",
+			AccessToken:    "test-access-token",
+			APIURL:         fake.URL,
+			FilePath:       path,
+			InitialComment: "Coverage report",
+			Title:          "Coverage",
+		},
 	}
+	output := setTestOutput(t)
 
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		out, _ := io.ReadAll(r.Body)
-		got := string(out)
+	assert.NilError(t, plugin.Exec())
 
-		want := `{"attachments":[{"color":"good","fallback":"success octocat/hello-world#7fd1a60b (master) by octocat","text":"*success* \u003chttp://github.com/octocat/hello-world|octocat/hello-world#7fd1a60b\u003e (master) by octocat","mrkdwn_in":["text","fallback"],"blocks":null}],"replace_original":false,"delete_original":false}`
-		assert.Equal(t, got, want)
-	}
+	urls := fake.calls("files.getUploadURLExternal")
+	assert.Equal(t, len(urls), 1)
+	assert.Equal(t, urls[0].Get("filename"), "coverage.html")
+	assert.Equal(t, urls[0].Get("length"), "13")
+	assert.Equal(t, len(fake.calls("upload")), 1)
 
-	server := httptest.NewServer(http.HandlerFunc(handler))
-	defer server.Close()
-
-	plugin.Config.Webhook = server.URL
+	completes := fake.calls("files.completeUploadExternal")
+	assert.Equal(t, len(completes), 1)
+	assert.Equal(t, completes[0].Get("channel_id"), "CBUILDS")
+	assert.Equal(t, completes[0].Get("initial_comment"), "Coverage report")
+	assert.Equal(t, completes[0].Get("files"), `[{"id":"FFAKE","title":"Coverage"}]`)
 
-	_ = plugin.Exec()
+	assert.Assert(t, strings.Contains(readTestOutput(t, output), "UPLOAD_OK_STATUS=Success"))
 }
 
 func TestGetSlackIdFromEmail(t *testing.T) {
-	config := Config{
-		AccessToken: "test-access-token",
-		SlackIdOf:   "octocat@github.com",
-	}
+	fake := newFakeSlack(t)
+	fake.Users["octocat@github.com"] = "U12345"
 
 	plugin := Plugin{
-		Config: config,
-	}
-
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		response := `{
-			"user": {
-				"id": "U12345",
-				"name": "octocat"
-			}
-		}`
-		_, _ = w.Write([]byte(response))
+		Config: Config{
+			AccessToken: "test-access-token",
+			APIURL:      fake.URL,
+			SlackIdOf:   "octocat@github.com",
+		},
 	}
+	output := setTestOutput(t)
 
-	server := httptest.NewServer(http.HandlerFunc(handler))
-	defer server.Close()
+	assert.NilError(t, GetSlackIdFromEmail(&plugin))
+	assert.Equal(t, readTestOutput(t, output), "SLACK_ID_FROM_EMAIL=U12345\n")
 
-	plugin.Config.AccessToken = server.URL
+	lookups := fake.calls("users.lookupByEmail")
+	assert.Equal(t, len(lookups), 1)
+	assert.Equal(t, lookups[0].Get("email"), "octocat@github.com")
 
+	// Unknown users are reported, without dropping the users that resolved
+	plugin.Config.SlackIdOf = "octocat@github.com,hubot@github.com"
 	err := GetSlackIdFromEmail(&plugin)
-	assert.NilError(t, err, "should retrieve Slack ID without error")
+	assert.ErrorContains(t, err, "failed to fetch Slack IDs for emails: hubot@github.com")
+	assert.Equal(t, readTestOutput(t, output), "SLACK_ID_FROM_EMAIL=U12345\nSLACK_ID_FROM_EMAIL=U12345\n")
 }
 
 func TestGetSlackIdsOfCommitters(t *testing.T) {
@@ -286,6 +291,24 @@ func TestGetSlackIdsOfCommitters(t *testing.T) {
 	assert.DeepEqual(t, slackIDs, []string{"U12345", "U67890"})
 }
 
+func TestGetSlackIdsOfCommitters_PartialLookup(t *testing.T) {
+	plugin := Plugin{Config: Config{CommitterListGitPath: "/mock/repo/path"}}
+	output := setTestOutput(t)
+
+	mockGetAuthorsList := func(gitDir string) ([]string, error) {
+		return []string{"user1@example.com", "bot@example.com"}, nil
+	}
+	mockGetSlackUserIDByEmail := func(accessToken string, emailList string) ([]string, error) {
+		return []string{"U12345"}, &emailLookupError{Emails: []string{"bot@example.com"}}
+	}
+
+	slackIDs, err := GetSlackIdsOfCommitters(&plugin, mockGetAuthorsList, mockGetSlackUserIDByEmail)
+	assert.ErrorContains(t, err, "bot@example.com")
+	assert.Assert(t, isPartialLookup(err))
+	assert.DeepEqual(t, slackIDs, []string{"U12345"})
+	assert.Equal(t, readTestOutput(t, output), "COMMITTERS_SLACK_IDS=U12345\n")
+}
+
 func TestGetSlackIdsOfCommitters_NoCommitters(t *testing.T) {
 	config := Config{
 		AccessToken:          "mock-access-token",
@@ -374,3 +397,12 @@ func TestGetSlackIdsOfCommitters_SlackRateLimit(t *testing.T) {
 		t.Errorf("mismatch in Slack IDs (-want +got):\n%s", diff)
 	}
 }
+
+func TestIsSlackError(t *testing.T) {
+	err := fmt.Errorf("failed to send ephemeral slack message: %w", slack.SlackErrorResponse{Err: "user_not_in_channel"})
+
+	assert.Assert(t, isSlackError(err, "user_not_in_channel"))
+	assert.Assert(t, !isSlackError(err, "channel_not_found"))
+	assert.Assert(t, !isSlackError(fmt.Errorf("user_not_in_channel"), "user_not_in_channel"))
+	assert.Assert(t, !isSlackError(nil, "user_not_in_channel"))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrorPolicy decides what happens to the build when a Slack operation fails.
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyFail fails the build.
+	ErrorPolicyFail ErrorPolicy = "fail"
+	// ErrorPolicyWarn logs the error and passes the build.
+	ErrorPolicyWarn ErrorPolicy = "warn"
+	// ErrorPolicyIgnore silently passes the build.
+	ErrorPolicyIgnore ErrorPolicy = "ignore"
+)
+
+// Operations reported through the error policy. Each one writes
+// <OPERATION>_STATUS (and <OPERATION>_ERROR on failure) to DRONE_OUTPUT.
+const (
+	opPostMessage      = "POST_MESSAGE"
+	opWebhook          = "WEBHOOK"
+	opUploadFile       = "UPLOAD_FILE"
+	opEmailLookup      = "EMAIL_LOOKUP"
+	opCommitterLookup  = "COMMITTER_LOOKUP"
+	opCommitterMessage = "COMMITTER_MESSAGE"
+	opScheduleMessage  = "SCHEDULE_MESSAGE"
+	opCancelSchedule   = "CANCEL_SCHEDULED_MESSAGE"
+	opReactions        = "REACTIONS"
+	opPin              = "PIN"
+	opFindMessage      = "FIND_MESSAGE"
+	opUpdateMessage    = "UPDATE_MESSAGE"
+	opStatusChange     = "STATUS_CHANGE"
+	opSuppress         = "SUPPRESS"
+)
+
+// defaultErrorPolicy is the policy of op without PLUGIN_ERROR_POLICY. A
+// message that wasn't delivered fails the build, as it always did, while
+// failed uploads and the other operations pass it, as uploads always did
+// unless PLUGIN_FAIL_ON_ERROR was set.
+func defaultErrorPolicy(op string) ErrorPolicy {
+	switch op {
+	case opPostMessage, opWebhook, opScheduleMessage:
+		return ErrorPolicyFail
+	default:
+		return ErrorPolicyWarn
+	}
+}
+
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+// resolveErrorPolicy parses PLUGIN_ERROR_POLICY. Without one, the deprecated
+// PLUGIN_FAIL_ON_ERROR=true selects fail, and otherwise it's empty so the
+// default of each operation applies.
+func resolveErrorPolicy(s string, failOnError bool) (ErrorPolicy, error) {
+	if strings.TrimSpace(s) == "" && failOnError {
+		return ErrorPolicyFail, nil
+	}
+	return parseErrorPolicy(s)
+}
+
+func parseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch policy := ErrorPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "", ErrorPolicyFail, ErrorPolicyWarn, ErrorPolicyIgnore:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid error policy %q, must be one of fail, warn or ignore", s)
+	}
+}
+
+// applyErrorPolicy records the outcome of op in DRONE_OUTPUT and returns err
+// only when the configured policy says the build should fail.
+func (p Plugin) applyErrorPolicy(op string, err error) error {
+	status := statusSuccess
+	if err != nil {
+		status = statusFailure
+	}
+
+	if werr := WriteEnvToOutputFile(op+"_STATUS", status); werr != nil {
+		log.Printf("Failed to write %s status to output file: %v", op, werr)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	if werr := WriteEnvToOutputFile(op+"_ERROR", singleLine(err.Error())); werr != nil {
+		log.Printf("Failed to write %s error to output file: %v", op, werr)
+	}
+
+	policy := p.Config.ErrorPolicy
+	if policy == "" {
+		policy = defaultErrorPolicy(op)
+	}
+	p.report.addError(ErrorRecord{Operation: op, Policy: policy, Error: err.Error()})
+
+	switch policy {
+	case ErrorPolicyIgnore:
+		return nil
+	case ErrorPolicyWarn:
+		log.Printf("%s failed but passing build as error policy is %s: %v", op, ErrorPolicyWarn, err)
+		return nil
+	default:
+		return err
+	}
+}
+
+// singleLine folds a multi-line value so it fits a KEY=value output line.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
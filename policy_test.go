@@ -0,0 +1,326 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseErrorPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		Value       string
+		FailOnError bool
+		Expect      ErrorPolicy
+		Err         bool
+	}{
+		"Default":     {Value: "", Expect: ""},
+		"FailOnError": {Value: "", FailOnError: true, Expect: ErrorPolicyFail},
+		"Fail":        {Value: "fail", Expect: ErrorPolicyFail},
+		"Warn":        {Value: "WARN", Expect: ErrorPolicyWarn},
+		"Ignore":      {Value: " ignore ", Expect: ErrorPolicyIgnore},
+		"Overridden":  {Value: "ignore", FailOnError: true, Expect: ErrorPolicyIgnore},
+		"Invalid":     {Value: "retry", Err: true},
+	}
+
+	for name, testCase := range testCases {
+		policy, err := resolveErrorPolicy(testCase.Value, testCase.FailOnError)
+		if testCase.Err {
+			assert.ErrorContains(t, err, "invalid error policy", name)
+			continue
+		}
+		assert.NilError(t, err, name)
+		assert.Equal(t, testCase.Expect, policy, name)
+	}
+}
+
+// policyCase drives one operation through Exec against the fake Slack API.
+// plugin sets the operation up so it succeeds, and fail makes it fail.
+type policyCase struct {
+	op     string
+	err    string
+	plugin func(t *testing.T, fake *fakeSlack) Plugin
+	fail   func(fake *fakeSlack)
+}
+
+func policyCases() []policyCase {
+	failMethod := func(method, code string) func(fake *fakeSlack) {
+		return func(fake *fakeSlack) { fake.Errors[method] = code }
+	}
+
+	return []policyCase{
+		{
+			op:     opPostMessage,
+			err:    "channel_not_found",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin { return getFakeSlackPlugin(fake) },
+			fail:   failMethod("chat.postMessage", "channel_not_found"),
+		},
+		{
+			op:  opWebhook,
+			err: "500",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if _, ok := fake.Errors["webhook"]; ok {
+						w.WriteHeader(http.StatusInternalServerError)
+					}
+				}))
+				t.Cleanup(server.Close)
+
+				plugin := getTestPlugin()
+				plugin.Config.Webhook = server.URL
+				return plugin
+			},
+			fail: failMethod("webhook", ""),
+		},
+		{
+			op:  opUploadFile,
+			err: "invalid_auth",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.FilePath = filepath.Join(t.TempDir(), "report.txt")
+				assert.NilError(t, os.WriteFile(plugin.Config.FilePath, []byte("report"), 0644))
+				return plugin
+			},
+			fail: failMethod("files.getUploadURLExternal", "invalid_auth"),
+		},
+		{
+			op:  opEmailLookup,
+			err: "hubot@github.com",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				fake.Users["hubot@github.com"] = "U1"
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.SlackIdOf = "hubot@github.com"
+				return plugin
+			},
+			fail: func(fake *fakeSlack) { delete(fake.Users, "hubot@github.com") },
+		},
+		{
+			op:  opCommitterLookup,
+			err: "hubot@github.com",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				fake.Users["hubot@github.com"] = "U1"
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.Channel = ""
+				plugin.Config.CommitterSlackId = true
+				plugin.Config.CommitterListGitPath = newTestGitRepo(t, "octocat@github.com", "hubot@github.com")
+				return plugin
+			},
+			fail: func(fake *fakeSlack) { delete(fake.Users, "hubot@github.com") },
+		},
+		{
+			op:  opCommitterMessage,
+			err: "cannot_dm_bot",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				fake.Users["hubot@github.com"] = "U1"
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.CommitterSlackId = true
+				plugin.Config.CommitterListGitPath = newTestGitRepo(t, "octocat@github.com", "hubot@github.com")
+				return plugin
+			},
+			fail: failMethod("conversations.open", "cannot_dm_bot"),
+		},
+		{
+			op:  opScheduleMessage,
+			err: "time_in_past",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.SendAt = "1h"
+				return plugin
+			},
+			fail: failMethod("chat.scheduleMessage", "time_in_past"),
+		},
+		{
+			op:  opCancelSchedule,
+			err: "invalid_scheduled_message_id",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.CancelScheduledID = "QFAKE"
+				return plugin
+			},
+			fail: failMethod("chat.deleteScheduledMessage", "invalid_scheduled_message_id"),
+		},
+		{
+			op:  opReactions,
+			err: "message_not_found",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.Reactions = true
+				plugin.Config.MessageTs = "1700000000.000001"
+				return plugin
+			},
+			fail: failMethod("reactions.add", "message_not_found"),
+		},
+		{
+			op:  opPin,
+			err: "not_pinnable",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Build.Event = "tag"
+				plugin.Config.PinReleases = true
+				return plugin
+			},
+			fail: failMethod("pins.add", "not_pinnable"),
+		},
+		{
+			op:  opFindMessage,
+			err: "not_in_channel",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.FindBuildMessage = FindBuildMessageUpdate
+				return plugin
+			},
+			fail: failMethod("conversations.history", "not_in_channel"),
+		},
+		{
+			op:  opUpdateMessage,
+			err: "cant_update_message",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.FindBuildMessage = FindBuildMessageUpdate
+				// The message of the build to update
+				assert.NilError(t, plugin.Exec())
+				return plugin
+			},
+			fail: failMethod("chat.update", "cant_update_message"),
+		},
+		{
+			op:  opStatusChange,
+			err: "not_in_channel",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.NotifyOnChange = true
+				return plugin
+			},
+			fail: failMethod("conversations.history", "not_in_channel"),
+		},
+		{
+			op:  opSuppress,
+			err: "not_in_channel",
+			plugin: func(t *testing.T, fake *fakeSlack) Plugin {
+				plugin := getFakeSlackPlugin(fake)
+				plugin.Config.SuppressWindow = time.Hour
+				return plugin
+			},
+			fail: failMethod("conversations.history", "not_in_channel"),
+		},
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	policies := []ErrorPolicy{ErrorPolicyFail, ErrorPolicyWarn, ErrorPolicyIgnore}
+
+	for _, c := range policyCases() {
+		for _, policy := range policies {
+			for _, failed := range []bool{false, true} {
+				name := c.op + "/" + string(policy)
+				if failed {
+					name += "/failed"
+				}
+
+				t.Run(name, func(t *testing.T) {
+					fake := newFakeSlack(t)
+					plugin := c.plugin(t, fake)
+					plugin.Config.ErrorPolicy = policy
+					if failed {
+						c.fail(fake)
+					}
+					output := setTestOutput(t)
+
+					err := plugin.Exec()
+					if failed && policy == ErrorPolicyFail {
+						assert.ErrorContains(t, err, c.err)
+					} else {
+						assert.NilError(t, err)
+					}
+
+					got := readTestOutput(t, output)
+					if failed {
+						assert.Assert(t, strings.Contains(got, c.op+"_STATUS=failure\n"), got)
+						assert.Assert(t, strings.Contains(got, c.op+"_ERROR="), got)
+					} else {
+						assert.Assert(t, strings.Contains(got, c.op+"_STATUS=success\n"), got)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestErrorPolicyDefault(t *testing.T) {
+	// Only undelivered messages fail the build without PLUGIN_ERROR_POLICY
+	fails := map[string]bool{opPostMessage: true, opWebhook: true, opScheduleMessage: true}
+
+	for _, c := range policyCases() {
+		t.Run(c.op, func(t *testing.T) {
+			fake := newFakeSlack(t)
+			plugin := c.plugin(t, fake)
+			plugin.Config.ErrorPolicy = ""
+			c.fail(fake)
+			setTestOutput(t)
+
+			err := plugin.Exec()
+			if fails[c.op] {
+				assert.ErrorContains(t, err, c.err)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestErrorPolicyCommitterLookup(t *testing.T) {
+	fake := newFakeSlack(t)
+	plugin := getFakeSlackPlugin(fake)
+	plugin.Config.CommitterSlackId = true
+	plugin.Config.CommitterListGitPath = newTestGitRepo(t, "hubot@github.com")
+	output := setTestOutput(t)
+
+	// No committer resolves, which is a failed lookup and not a failed message
+	assert.NilError(t, plugin.Exec())
+	got := readTestOutput(t, output)
+	assert.Assert(t, strings.Contains(got, opCommitterLookup+"_STATUS=failure\n"), got)
+	assert.Assert(t, strings.Contains(got, opCommitterMessage+"_STATUS=success\n"), got)
+}
+
+func TestErrorPolicyOperations(t *testing.T) {
+	// Every operation of policy.go has a case
+	ops := map[string]bool{}
+	for _, c := range policyCases() {
+		ops[c.op] = true
+	}
+	for _, op := range []string{
+		opPostMessage, opWebhook, opUploadFile, opEmailLookup, opCommitterLookup,
+		opCommitterMessage, opScheduleMessage, opCancelSchedule, opReactions, opPin,
+		opFindMessage, opUpdateMessage, opStatusChange, opSuppress,
+	} {
+		assert.Assert(t, ops[op], op)
+	}
+}
+
+func TestErrorPolicySingleLine(t *testing.T) {
+	output := setTestOutput(t)
+	plugin := Plugin{Config: Config{ErrorPolicy: ErrorPolicyWarn}}
+
+	assert.NilError(t, plugin.applyErrorPolicy(opWebhook, errors.New("slack said\nno")))
+	assert.Equal(t, readTestOutput(t, output), "WEBHOOK_STATUS=failure\nWEBHOOK_ERROR=slack said no\n")
+}
+
+func setTestOutput(t *testing.T) string {
+	output := filepath.Join(t.TempDir(), "output.env")
+	t.Setenv("DRONE_OUTPUT", output)
+	return output
+}
+
+func readTestOutput(t *testing.T, output string) string {
+	b, err := os.ReadFile(output)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	// Embedded so quiet hours timezones work in images without tzdata
+	_ "time/tzdata"
+)
+
+// Actions taken on notifications during quiet hours.
+const (
+	QuietDefer     = "defer"
+	QuietNoMention = "nomention"
+	QuietDrop      = "drop"
+	QuietSend      = "send"
+)
+
+// DefaultQuietBypassBranches are the branches whose failures are always sent
+// right away.
+const DefaultQuietBypassBranches = "release,release/*,release-*"
+
+// quietSearchLimit bounds how far ahead the end of quiet hours is searched.
+const quietSearchLimit = 14 * 24 * time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type (
+	// quietHours is the parsed quiet hours configuration.
+	quietHours struct {
+		ranges   []quietRange
+		holidays map[string]bool
+		location *time.Location
+	}
+
+	// quietRange is quiet on its days from start until end, in minutes since
+	// midnight. A range ending before it starts runs into the next day.
+	quietRange struct {
+		days       [7]bool
+		start, end int
+	}
+)
+
+// parseQuietHours reads comma-separated ranges like "22:00-07:00",
+// "Mon-Fri 19:00-08:00" or "Sat-Sun", holiday dates like "2024-12-25" and an
+// IANA timezone, UTC by default.
+func parseQuietHours(ranges, holidays, timezone string) (quietHours, error) {
+	q := quietHours{holidays: map[string]bool{}, location: time.UTC}
+
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return q, fmt.Errorf("invalid quiet hours timezone %q: %w", timezone, err)
+		}
+		q.location = location
+	}
+
+	for _, s := range strings.Split(ranges, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		r, err := parseQuietRange(s)
+		if err != nil {
+			return q, err
+		}
+		q.ranges = append(q.ranges, r)
+	}
+
+	for _, s := range strings.Split(holidays, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return q, fmt.Errorf("invalid holiday %q, must be YYYY-MM-DD", s)
+		}
+		q.holidays[s] = true
+	}
+
+	return q, nil
+}
+
+func parseQuietRange(s string) (quietRange, error) {
+	r := quietRange{start: 0, end: 24 * 60}
+
+	var days, times string
+	switch fields := strings.Fields(s); {
+	case len(fields) == 2:
+		days, times = fields[0], fields[1]
+	case len(fields) == 1 && strings.Contains(fields[0], ":"):
+		times = fields[0]
+	case len(fields) == 1:
+		// Only days, quiet all day
+		days = fields[0]
+	default:
+		return r, fmt.Errorf("invalid quiet hours %q", s)
+	}
+
+	if days == "" {
+		for i := range r.days {
+			r.days[i] = true
+		}
+	} else {
+		var err error
+		if r.days, err = parseWeekdays(days); err != nil {
+			return r, fmt.Errorf("invalid quiet hours %q: %w", s, err)
+		}
+	}
+
+	if times == "" {
+		return r, nil
+	}
+
+	from, to, ok := strings.Cut(times, "-")
+	if !ok {
+		return r, fmt.Errorf("invalid quiet hours %q, must be HH:MM-HH:MM", s)
+	}
+	var err error
+	if r.start, err = parseClock(from); err != nil {
+		return r, fmt.Errorf("invalid quiet hours %q: %w", s, err)
+	}
+	if r.end, err = parseClock(to); err != nil {
+		return r, fmt.Errorf("invalid quiet hours %q: %w", s, err)
+	}
+	if r.start == r.end {
+		return r, fmt.Errorf("invalid quiet hours %q, start and end are the same", s)
+	}
+	return r, nil
+}
+
+// parseWeekdays reads a day like "Sat" or a range like "Mon-Fri" or "Fri-Mon".
+func parseWeekdays(s string) ([7]bool, error) {
+	var days [7]bool
+
+	from, to, isRange := strings.Cut(strings.ToLower(s), "-")
+	first, ok := weekdays[from]
+	if !ok {
+		return days, fmt.Errorf("unknown weekday %q", from)
+	}
+	last := first
+	if isRange {
+		if last, ok = weekdays[to]; !ok {
+			return days, fmt.Errorf("unknown weekday %q", to)
+		}
+	}
+
+	for d := first; ; d = (d + 1) % 7 {
+		days[d] = true
+		if d == last {
+			return days, nil
+		}
+	}
+}
+
+// parseClock reads HH:MM, where 24:00 is the end of the day, as minutes.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, must be HH:MM", s)
+	}
+	return h*60 + m, nil
+}
+
+// quiet reports whether t falls within quiet hours.
+func (q quietHours) quiet(t time.Time) bool {
+	local := t.In(q.location)
+	if q.holidays[local.Format(time.DateOnly)] {
+		return true
+	}
+
+	day := local.Weekday()
+	previous := (day + 6) % 7
+	minute := local.Hour()*60 + local.Minute()
+
+	for _, r := range q.ranges {
+		if r.start < r.end {
+			if r.days[day] && minute >= r.start && minute < r.end {
+				return true
+			}
+			continue
+		}
+		if (r.days[day] && minute >= r.start) || (r.days[previous] && minute < r.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// end returns the first minute after t that is outside quiet hours.
+func (q quietHours) end(t time.Time) (time.Time, error) {
+	next := t.Truncate(time.Minute)
+	for limit := t.Add(quietSearchLimit); next.Before(limit); next = next.Add(time.Minute) {
+		if !q.quiet(next) {
+			return next, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("quiet hours don't end within %s", quietSearchLimit)
+}
+
+// quietAction decides what happens to the notification at now, or returns
+// QuietSend outside quiet hours. Failures on the bypass branches are always
+// sent, and Config.QuietActions overrides Config.QuietAction per status.
+func (p Plugin) quietAction(now time.Time) (string, time.Time, error) {
+	q, err := parseQuietHours(p.Config.QuietHours, p.Config.QuietHolidays, p.Config.QuietTimezone)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !q.quiet(now) {
+		return QuietSend, time.Time{}, nil
+	}
+
+	if color(p.Build) == "danger" && matchesBranch(p.Config.QuietBypassBranches, p.Build.Branch) {
+		log.Printf("Quiet hours bypassed for %s on %s", p.Build.Status, p.Build.Branch)
+		return QuietSend, time.Time{}, nil
+	}
+
+	actions, err := parsePairs(p.Config.QuietActions)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid quiet actions: %w", err)
+	}
+	action, ok := actions[p.Build.Status]
+	if !ok {
+		action = p.Config.QuietAction
+	}
+	if action == "" {
+		action = QuietDefer
+	}
+
+	switch action {
+	case QuietSend, QuietNoMention, QuietDrop:
+		return action, time.Time{}, nil
+	case QuietDefer:
+		end, err := q.end(now)
+		return action, end, err
+	}
+	return "", time.Time{}, fmt.Errorf("invalid quiet action %q", action)
+}
+
+// matchesBranch reports whether branch matches any of the comma-separated
+// glob patterns.
+func matchesBranch(patterns, branch string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var mentionPattern = regexp.MustCompile(`<([@!])([^>|]+)(?:\|([^>]*))?>`)
+
+// stripMentions turns user, group and channel mentions into plain text that
+// doesn't notify anyone.
+func stripMentions(text string) string {
+	return mentionPattern.ReplaceAllStringFunc(text, func(mention string) string {
+		parts := mentionPattern.FindStringSubmatch(mention)
+		if parts[3] != "" {
+			return prepend("@", parts[3])
+		}
+		if parts[1] == "!" {
+			// <!here>, <!channel>, <!subteam^ID>
+			name, _, _ := strings.Cut(parts[2], "^")
+			return "@" + name
+		}
+		return "@" + parts[2]
+	})
+}
+
+// stripBlockMentions strips mentions from all text in the blocks.
+func stripBlockMentions(blocks []slack.Block) ([]slack.Block, error) {
+	b, err := json.Marshal(slack.Blocks{BlockSet: blocks})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode blocks: %w", err)
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("could not decode blocks: %w", err)
+	}
+	if b, err = json.Marshal(stripValueMentions(raw)); err != nil {
+		return nil, fmt.Errorf("could not encode blocks: %w", err)
+	}
+
+	var stripped slack.Blocks
+	if err := json.Unmarshal(b, &stripped); err != nil {
+		return nil, fmt.Errorf("could not decode blocks: %w", err)
+	}
+	return stripped.BlockSet, nil
+}
+
+// stripValueMentions strips mentions from every string in decoded JSON.
+func stripValueMentions(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return stripMentions(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = stripValueMentions(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = stripValueMentions(v[k])
+		}
+	}
+	return v
+}
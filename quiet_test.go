@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestQuietHours(t *testing.T) {
+	q, err := parseQuietHours("Mon-Fri 22:00-07:00, Sat-Sun", "2024-12-25", "Europe/Berlin")
+	assert.NilError(t, err)
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NilError(t, err)
+
+	testCases := []struct {
+		Time  time.Time
+		Quiet bool
+	}{
+		// Tuesday
+		{time.Date(2024, 12, 3, 12, 0, 0, 0, berlin), false},
+		{time.Date(2024, 12, 3, 22, 0, 0, 0, berlin), true},
+		{time.Date(2024, 12, 3, 6, 59, 0, 0, berlin), true},
+		{time.Date(2024, 12, 3, 7, 0, 0, 0, berlin), false},
+		// Sunday is quiet until midnight only
+		{time.Date(2024, 12, 2, 3, 0, 0, 0, berlin), false},
+		// Saturday morning belongs to Friday night
+		{time.Date(2024, 12, 7, 3, 0, 0, 0, berlin), true},
+		{time.Date(2024, 12, 7, 15, 0, 0, 0, berlin), true},
+		// Holiday on a Wednesday
+		{time.Date(2024, 12, 25, 12, 0, 0, 0, berlin), true},
+		// 12:00 UTC is 13:00 in Berlin
+		{time.Date(2024, 12, 3, 12, 0, 0, 0, time.UTC), false},
+		{time.Date(2024, 12, 3, 21, 30, 0, 0, time.UTC), true},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, q.quiet(testCase.Time), testCase.Quiet, testCase.Time.String())
+	}
+
+	end, err := q.end(time.Date(2024, 12, 6, 23, 15, 30, 0, berlin))
+	assert.NilError(t, err)
+	assert.Assert(t, end.Equal(time.Date(2024, 12, 9, 0, 0, 0, 0, berlin)), end.String())
+}
+
+func TestParseQuietHoursInvalid(t *testing.T) {
+	testCases := []struct {
+		Ranges   string
+		Holidays string
+		Timezone string
+	}{
+		{Ranges: "22:00"},
+		{Ranges: "25:00-07:00"},
+		{Ranges: "22:00-22:00"},
+		{Ranges: "Someday 22:00-07:00"},
+		{Ranges: "Mon 22:00-07:00 extra"},
+		{Holidays: "25.12.2024"},
+		{Timezone: "Nowhere/City"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := parseQuietHours(testCase.Ranges, testCase.Holidays, testCase.Timezone)
+		assert.Assert(t, err != nil, "%+v", testCase)
+	}
+}
+
+func TestQuietAction(t *testing.T) {
+	night := time.Date(2024, 12, 3, 3, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Name    string
+		Time    time.Time
+		Status  string
+		Branch  string
+		Actions string
+		Expect  string
+	}{
+		{Name: "outside quiet hours", Time: night.Add(6 * time.Hour), Status: "failure", Branch: "master", Expect: QuietSend},
+		{Name: "default action", Time: night, Status: "success", Branch: "master", Expect: QuietDefer},
+		{Name: "status action", Time: night, Status: "success", Branch: "master", Actions: "success=drop", Expect: QuietDrop},
+		{Name: "release failure", Time: night, Status: "failure", Branch: "release/1.2", Actions: "failure=drop", Expect: QuietSend},
+		{Name: "release success", Time: night, Status: "success", Branch: "release/1.2", Actions: "success=nomention", Expect: QuietNoMention},
+	}
+
+	for _, testCase := range testCases {
+		plugin := getTestPlugin()
+		plugin.Build.Status = testCase.Status
+		plugin.Build.Branch = testCase.Branch
+		plugin.Config.QuietHours = "22:00-07:00"
+		plugin.Config.QuietActions = testCase.Actions
+		plugin.Config.QuietBypassBranches = DefaultQuietBypassBranches
+
+		action, deferUntil, err := plugin.quietAction(testCase.Time)
+		assert.NilError(t, err, testCase.Name)
+		assert.Equal(t, action, testCase.Expect, testCase.Name)
+		if action == QuietDefer {
+			assert.Assert(t, deferUntil.Equal(time.Date(2024, 12, 3, 7, 0, 0, 0, time.UTC)), testCase.Name)
+		}
+	}
+}
+
+func TestStripMentions(t *testing.T) {
+	testCases := []struct {
+		Text   string
+		Expect string
+	}{
+		{"<@U123>: build failed", "@U123: build failed"},
+		{"<@U123|octocat> broke it", "@octocat broke it"},
+		{"<!here> <!channel>", "@here @channel"},
+		{"<!subteam^S123|@oncall> look", "@oncall look"},
+		{"see <https://example.com|the build>", "see <https://example.com|the build>"},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, stripMentions(testCase.Text), testCase.Expect)
+	}
+
+	blocks, err := stripBlockMentions([]slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "<@U123> build <https://example.com|failed>", false, false), nil, nil),
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, blocks[0].(*slack.SectionBlock).Text.Text, "@U123 build <https://example.com|failed>")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// defaultReactions maps the color() status buckets to emoji.
+var defaultReactions = map[string]string{
+	"good":    "white_check_mark",
+	"danger":  "x",
+	"warning": "hourglass",
+}
+
+// parseReactions reads a comma separated list of key=emoji pairs, where key
+// is a build status or one of the color() buckets. Colons around the emoji
+// name are optional.
+func parseReactions(s string) (map[string]string, error) {
+	pairs, err := parsePairs(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reaction mapping: %w", err)
+	}
+
+	reactions := map[string]string{}
+	for k, v := range defaultReactions {
+		reactions[k] = v
+	}
+	for k, v := range pairs {
+		reactions[k] = strings.Trim(v, ":")
+	}
+
+	return reactions, nil
+}
+
+// reactionFor picks the emoji for the build, preferring an exact status
+// match over the status bucket.
+func reactionFor(reactions map[string]string, build Build) string {
+	if emoji, ok := reactions[build.Status]; ok {
+		return emoji
+	}
+	return reactions[color(build)]
+}
+
+// Reactions replaces the plugin's reaction on the message identified by
+// Channel and MessageTs with the one matching the build status.
+func (p Plugin) Reactions() error {
+	if p.Config.Channel == "" || p.Config.MessageTs == "" {
+		return errors.New("reactions need both a channel and a message ts")
+	}
+	if !isChannelID(p.Config.Channel) {
+		return fmt.Errorf("reactions need a channel ID like C0123456789, not %q", p.Config.Channel)
+	}
+
+	reactions, err := parseReactions(p.Config.ReactionEmojis)
+	if err != nil {
+		return err
+	}
+
+	emoji := strings.Trim(p.Config.Reaction, ":")
+	if emoji == "" {
+		emoji = reactionFor(reactions, p.Build)
+	}
+
+	// Remove every other emoji the plugin may have added before
+	var remove []string
+	seen := map[string]bool{emoji: true}
+	for _, other := range reactions {
+		if !seen[other] {
+			seen[other] = true
+			remove = append(remove, other)
+		}
+	}
+	sort.Strings(remove)
+
+	start := time.Now()
+	api := p.slackClient()
+	err = updateReactions(api, p.Config.Channel, p.Config.MessageTs, emoji, remove)
+	p.report.addReaction(ReactionRecord{
+		Timing:    timingSince(start),
+		Channel:   p.Config.Channel,
+		Timestamp: p.Config.MessageTs,
+		Added:     emoji,
+		Removed:   remove,
+		Error:     errorString(err),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Reacted to message %s with :%s:", p.Config.MessageTs, emoji)
+	return nil
+}
+
+// updateReactions removes the given reactions and adds one. Removing a
+// reaction that isn't there, or adding one that already is, is not an error.
+func updateReactions(api *slack.Client, channel, ts, add string, remove []string) error {
+	item := slack.NewRefToMessage(channel, ts)
+
+	for _, emoji := range remove {
+		err := api.RemoveReaction(emoji, item)
+		if err != nil && !isSlackError(err, "no_reaction") {
+			return fmt.Errorf("failed to remove reaction %s: %w", emoji, err)
+		}
+	}
+
+	if add == "" {
+		return nil
+	}
+
+	err := api.AddReaction(add, item)
+	if err != nil && !isSlackError(err, "already_reacted") {
+		return fmt.Errorf("failed to add reaction %s: %w", add, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestReactionFor(t *testing.T) {
+	reactions, err := parseReactions("running=:hourglass_flowing_sand:, killed=skull")
+	assert.NilError(t, err)
+
+	testCases := map[string]string{
+		"success": "white_check_mark",
+		"failure": "x",
+		"error":   "x",
+		"killed":  "skull",
+		"running": "hourglass_flowing_sand",
+		"pending": "hourglass",
+	}
+
+	for status, emoji := range testCases {
+		assert.Equal(t, reactionFor(reactions, Build{Status: status}), emoji, status)
+	}
+}
+
+func TestParseReactionsInvalid(t *testing.T) {
+	_, err := parseReactions("success")
+	assert.ErrorContains(t, err, "invalid reaction mapping")
+}
+
+func TestUpdateReactions(t *testing.T) {
+	var calls []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, r.ParseForm())
+		assert.Equal(t, r.PostForm.Get("channel"), "C123")
+		assert.Equal(t, r.PostForm.Get("timestamp"), "1700000000.000100")
+		calls = append(calls, r.URL.Path+" "+r.PostForm.Get("name"))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("name") {
+		case "hourglass":
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "x":
+			_, _ = w.Write([]byte(`{"ok":false,"error":"no_reaction"}`))
+		case "white_check_mark":
+			_, _ = w.Write([]byte(`{"ok":false,"error":"already_reacted"}`))
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	api := slack.New("test-access-token", slack.OptionAPIURL(server.URL+"/"))
+	err := updateReactions(api, "C123", "1700000000.000100", "white_check_mark", []string{"hourglass", "x"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, calls, []string{
+		"/reactions.remove hourglass",
+		"/reactions.remove x",
+		"/reactions.add white_check_mark",
+	})
+}
+
+func TestUpdateReactionsError(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":false,"error":"message_not_found"}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	api := slack.New("test-access-token", slack.OptionAPIURL(server.URL+"/"))
+	err := updateReactions(api, "C123", "1700000000.000100", "x", nil)
+	assert.ErrorContains(t, err, "message_not_found")
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Masterminds/semver"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Kinds of releases.
+const (
+	ReleaseMajor      = "major"
+	ReleaseMinor      = "minor"
+	ReleasePatch      = "patch"
+	ReleasePrerelease = "prerelease"
+)
+
+// Release is the tag of a tag build parsed as a semantic version.
+type Release struct {
+	Tag        string
+	Version    string
+	Major      int64
+	Minor      int64
+	Patch      int64
+	Prerelease string
+	Metadata   string
+	// major, minor, patch or prerelease
+	Kind         string
+	IsPrerelease bool
+	// Closest earlier version tag, empty for the first release
+	Previous        string
+	PreviousVersion string
+}
+
+// release parses Build.Tag and looks up the previous version tag in the git
+// repository. It returns nil when the tag isn't a semantic version.
+func (p Plugin) release() *Release {
+	if p.Build.Tag == "" {
+		return nil
+	}
+	version, err := semver.NewVersion(p.Build.Tag)
+	if err != nil {
+		return nil
+	}
+
+	var previous *semver.Version
+	previousTag := ""
+	tags, err := repoTags(p.gitRepoPath())
+	if err != nil {
+		log.Printf("Could not find the release before %s: %v", p.Build.Tag, err)
+	} else {
+		previousTag, previous = previousVersion(version, p.Build.Tag, tags)
+	}
+
+	return newRelease(p.Build.Tag, version, previousTag, previous)
+}
+
+// usesRelease reports whether anything reads Plugin.Release, so tag builds
+// without templates, changelogs or changes don't read the repository tags.
+func (p Plugin) usesRelease() bool {
+	if p.Build.Tag == "" {
+		return false
+	}
+	return p.Config.Template != "" || p.Config.Fallback != "" || p.Config.CustomTemplate != "" || p.Config.CustomBlock != "" ||
+		p.Config.Changelog || p.Config.Changes || p.Config.ChangesBlock
+}
+
+func newRelease(tag string, version *semver.Version, previousTag string, previous *semver.Version) *Release {
+	release := &Release{
+		Tag:          tag,
+		Version:      version.String(),
+		Major:        version.Major(),
+		Minor:        version.Minor(),
+		Patch:        version.Patch(),
+		Prerelease:   version.Prerelease(),
+		Metadata:     version.Metadata(),
+		IsPrerelease: version.Prerelease() != "",
+		Previous:     previousTag,
+	}
+	if previous != nil {
+		release.PreviousVersion = previous.String()
+	}
+	release.Kind = releaseKind(version, previous)
+	return release
+}
+
+// releaseKind compares version to the previous release, or judges it by its
+// own numbers without one.
+func releaseKind(version, previous *semver.Version) string {
+	switch {
+	case version.Prerelease() != "":
+		return ReleasePrerelease
+	case previous == nil && version.Minor() == 0 && version.Patch() == 0:
+		return ReleaseMajor
+	case previous == nil && version.Patch() == 0:
+		return ReleaseMinor
+	case previous == nil:
+		return ReleasePatch
+	case version.Major() > previous.Major():
+		return ReleaseMajor
+	case version.Major() == previous.Major() && version.Minor() > previous.Minor():
+		return ReleaseMinor
+	}
+	return ReleasePatch
+}
+
+// previousVersion returns the highest tag below version. Pre-releases only
+// count as previous versions of other pre-releases.
+func previousVersion(version *semver.Version, tag string, tags []string) (string, *semver.Version) {
+	var previousTag string
+	var previous *semver.Version
+	for _, t := range tags {
+		if t == tag {
+			continue
+		}
+		v, err := semver.NewVersion(t)
+		if err != nil || !v.LessThan(version) {
+			continue
+		}
+		if v.Prerelease() != "" && version.Prerelease() == "" {
+			continue
+		}
+		if previous == nil || previous.LessThan(v) {
+			previousTag, previous = t, v
+		}
+	}
+	return previousTag, previous
+}
+
+// gitRepoPath is the git repository of the build, the workspace unless
+// configured otherwise.
+func (p Plugin) gitRepoPath() string {
+	if p.Config.GitPath != "" {
+		return p.Config.GitPath
+	}
+	return droneWorkspace()
+}
+
+// repoTags returns the names of the tags of the repository at path.
+func repoTags(path string) ([]string, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	var tags []string
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return tags, nil
+}
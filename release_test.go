@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"gotest.tools/v3/assert"
+)
+
+func TestReleaseKind(t *testing.T) {
+	tests := []struct {
+		version, previous, kind string
+	}{
+		{"v2.0.0", "v1.9.3", ReleaseMajor},
+		{"v2.3.0", "v2.2.4", ReleaseMinor},
+		{"v2.2.5", "v2.2.4", ReleasePatch},
+		{"v3.0.0-rc.1", "v2.2.4", ReleasePrerelease},
+		{"v1.0.0", "", ReleaseMajor},
+		{"v0.3.0", "", ReleaseMinor},
+		{"v0.3.1", "", ReleasePatch},
+	}
+	for _, test := range tests {
+		var previous *semver.Version
+		if test.previous != "" {
+			previous = semver.MustParse(test.previous)
+		}
+		assert.Equal(t, releaseKind(semver.MustParse(test.version), previous), test.kind, test.version)
+	}
+}
+
+func TestPreviousVersion(t *testing.T) {
+	tags := []string{"v2.2.4", "v2.3.0-rc.1", "v2.2.10", "v2.3.0", "latest", "v1.0.0"}
+
+	tag, previous := previousVersion(semver.MustParse("v2.3.0"), "v2.3.0", tags)
+	assert.Equal(t, tag, "v2.2.10")
+	assert.Equal(t, previous.String(), "2.2.10")
+
+	tag, _ = previousVersion(semver.MustParse("v2.3.0-rc.2"), "v2.3.0-rc.2", tags)
+	assert.Equal(t, tag, "v2.3.0-rc.1")
+
+	tag, previous = previousVersion(semver.MustParse("v1.0.0"), "v1.0.0", tags)
+	assert.Equal(t, tag, "")
+	assert.Assert(t, previous == nil)
+}
+
+func TestRelease(t *testing.T) {
+	fixture := newGitFixture(t)
+	fixture.commit("octocat@github.com", "initial commit", nil)
+	fixture.tag("v2.2.4", "v2.3.0")
+	dir := fixture.Dir
+
+	plugin := getTestPlugin()
+	plugin.Config.GitPath = dir
+	plugin.Build.Tag = "v2.3.0"
+
+	assert.DeepEqual(t, plugin.release(), &Release{
+		Tag:             "v2.3.0",
+		Version:         "2.3.0",
+		Major:           2,
+		Minor:           3,
+		Kind:            ReleaseMinor,
+		Previous:        "v2.2.4",
+		PreviousVersion: "2.2.4",
+	})
+
+	plugin.Build.Tag = "nightly"
+	assert.Assert(t, plugin.release() == nil)
+	plugin.Build.Tag = ""
+	assert.Assert(t, plugin.release() == nil)
+
+	// Without a repository the release has no previous version
+	plugin.Config.GitPath = t.TempDir()
+	plugin.Build.Tag = "v2.3.1"
+	release := plugin.release()
+	assert.Equal(t, release.Kind, ReleasePatch)
+	assert.Equal(t, release.Previous, "")
+}
+
+func TestUsesRelease(t *testing.T) {
+	plugin := getTestPlugin()
+	plugin.Config = Config{}
+	plugin.Build.Tag = "v2.3.0"
+	assert.Assert(t, !plugin.usesRelease())
+
+	plugin.Config.CustomTemplate = "release_1"
+	assert.Assert(t, plugin.usesRelease())
+
+	plugin.Config.CustomTemplate = ""
+	plugin.Config.Changelog = true
+	assert.Assert(t, plugin.usesRelease())
+
+	plugin.Build.Tag = ""
+	assert.Assert(t, !plugin.usesRelease())
+}
+
+func TestReleaseTemplate(t *testing.T) {
+	fixture := newGitFixture(t)
+	fixture.commit("octocat@github.com", "initial commit", nil)
+	fixture.tag("v2.2.4", "v2.3.0")
+	dir := fixture.Dir
+
+	var body struct {
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Build.Event = "tag"
+	plugin.Build.Tag = "v2.3.0"
+	plugin.Config.GitPath = dir
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomTemplate = "release_1"
+	assert.NilError(t, plugin.Exec())
+
+	assert.Equal(t, body.Blocks[0].Text.Text, "hello-world v2.3.0 released :rocket:")
+	assert.Equal(t, body.Blocks[1].Text.Text, "*v2.3.0* (minor, previous v2.2.4)")
+
+	// Tags that aren't versions are announced as is
+	plugin.Build.Tag = "nightly"
+	assert.NilError(t, plugin.Exec())
+	assert.Equal(t, body.Blocks[1].Text.Text, "*nightly*")
+}
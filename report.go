@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Modes recorded in the report, one per branch of Plugin.Exec.
+const (
+	modeUploadFile      = "upload_file"
+	modeEmailLookup     = "email_lookup"
+	modeCommitterLookup = "committer_lookup"
+	modeMessage         = "message"
+	modeWebhook         = "webhook"
+	modeSchedule        = "schedule"
+	modeCancelSchedule  = "cancel_schedule"
+	modeReactions       = "reactions"
+)
+
+type (
+	// Report is the JSON document written to Config.OutputFile summarising
+	// every action the plugin took.
+	Report struct {
+		Mode           string           `json:"mode"`
+		Success        bool             `json:"success"`
+		Error          string           `json:"error,omitempty"`
+		Skipped        string           `json:"skipped,omitempty"`
+		StartedAt      time.Time        `json:"started_at"`
+		DurationMs     int64            `json:"duration_ms"`
+		Messages       []MessageRecord  `json:"messages"`
+		DirectMessages []MessageRecord  `json:"direct_messages"`
+		Uploads        []UploadRecord   `json:"uploads"`
+		Lookups        []LookupRecord   `json:"lookups"`
+		Reactions      []ReactionRecord `json:"reactions"`
+		Pins           []PinRecord      `json:"pins"`
+		Errors         []ErrorRecord    `json:"errors"`
+	}
+
+	// Timing is embedded in every record.
+	Timing struct {
+		StartedAt  time.Time `json:"started_at"`
+		DurationMs int64     `json:"duration_ms"`
+	}
+
+	MessageRecord struct {
+		Timing
+		Channel   string `json:"channel"`
+		User      string `json:"user,omitempty"`
+		Delivery  string `json:"delivery,omitempty"`
+		Timestamp string `json:"ts,omitempty"`
+		Updated   bool   `json:"updated,omitempty"`
+		Permalink string `json:"permalink,omitempty"`
+		Error     string `json:"error,omitempty"`
+
+		ScheduledMessageID string `json:"scheduled_message_id,omitempty"`
+		PostAt             int64  `json:"post_at,omitempty"`
+	}
+
+	UploadRecord struct {
+		Timing
+		Channel string `json:"channel"`
+		Path    string `json:"path"`
+		FileID  string `json:"file_id,omitempty"`
+		Title   string `json:"title,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	LookupRecord struct {
+		Timing
+		Kind   string   `json:"kind"`
+		Emails []string `json:"emails"`
+		IDs    []string `json:"ids"`
+		Error  string   `json:"error,omitempty"`
+	}
+
+	ReactionRecord struct {
+		Timing
+		Channel   string   `json:"channel"`
+		Timestamp string   `json:"ts"`
+		Added     string   `json:"added,omitempty"`
+		Removed   []string `json:"removed"`
+		Error     string   `json:"error,omitempty"`
+	}
+
+	PinRecord struct {
+		Timing
+		Action    string `json:"action"`
+		Channel   string `json:"channel"`
+		Timestamp string `json:"ts"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	ErrorRecord struct {
+		Operation string      `json:"operation"`
+		Policy    ErrorPolicy `json:"policy"`
+		Error     string      `json:"error"`
+	}
+)
+
+func newReport() *Report {
+	return &Report{
+		StartedAt:      time.Now(),
+		Messages:       []MessageRecord{},
+		DirectMessages: []MessageRecord{},
+		Uploads:        []UploadRecord{},
+		Lookups:        []LookupRecord{},
+		Reactions:      []ReactionRecord{},
+		Pins:           []PinRecord{},
+		Errors:         []ErrorRecord{},
+	}
+}
+
+// timingSince returns the timing of an action that started at start.
+func timingSince(start time.Time) Timing {
+	return Timing{
+		StartedAt:  start,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// The recording methods are no-ops on a nil report so callers don't need to
+// check whether an output file was configured.
+
+func (r *Report) setMode(mode string) {
+	if r == nil {
+		return
+	}
+	r.Mode = mode
+}
+
+// skip records why no notification was sent.
+func (r *Report) skip(reason string) {
+	if r == nil {
+		return
+	}
+	r.Skipped = reason
+}
+
+func (r *Report) addMessage(m MessageRecord) {
+	if r == nil {
+		return
+	}
+	r.Messages = append(r.Messages, m)
+}
+
+func (r *Report) addDirectMessage(m MessageRecord) {
+	if r == nil {
+		return
+	}
+	r.DirectMessages = append(r.DirectMessages, m)
+}
+
+func (r *Report) addUpload(u UploadRecord) {
+	if r == nil {
+		return
+	}
+	r.Uploads = append(r.Uploads, u)
+}
+
+func (r *Report) addLookup(l LookupRecord) {
+	if r == nil {
+		return
+	}
+	if l.Emails == nil {
+		l.Emails = []string{}
+	}
+	if l.IDs == nil {
+		l.IDs = []string{}
+	}
+	r.Lookups = append(r.Lookups, l)
+}
+
+func (r *Report) addReaction(rr ReactionRecord) {
+	if r == nil {
+		return
+	}
+	if rr.Removed == nil {
+		rr.Removed = []string{}
+	}
+	r.Reactions = append(r.Reactions, rr)
+}
+
+func (r *Report) addPin(pr PinRecord) {
+	if r == nil {
+		return
+	}
+	r.Pins = append(r.Pins, pr)
+}
+
+func (r *Report) addError(e ErrorRecord) {
+	if r == nil {
+		return
+	}
+	r.Errors = append(r.Errors, e)
+}
+
+// write finalises the report with the outcome of the run and writes it to path.
+func (r *Report) write(path string, err error) error {
+	if r == nil {
+		return nil
+	}
+
+	r.Success = err == nil
+	r.Error = errorString(err)
+	r.DurationMs = time.Since(r.StartedAt).Milliseconds()
+
+	b, merr := json.MarshalIndent(r, "", "  ")
+	if merr != nil {
+		return fmt.Errorf("failed to encode report: %w", merr)
+	}
+
+	if werr := os.WriteFile(path, append(b, '\n'), 0644); werr != nil {
+		return fmt.Errorf("failed to write report: %w", werr)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReportWebhook(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = server.URL
+	plugin.Config.Channel = "builds"
+	plugin.Config.OutputFile = filepath.Join(t.TempDir(), "report.json")
+
+	assert.NilError(t, plugin.Exec())
+
+	report := readTestReport(t, plugin.Config.OutputFile)
+	assert.Equal(t, report.Mode, modeWebhook)
+	assert.Assert(t, report.Success)
+	assert.Equal(t, len(report.Messages), 1)
+	assert.Equal(t, report.Messages[0].Channel, "#builds")
+	assert.Equal(t, report.Messages[0].Error, "")
+	assert.Equal(t, len(report.Errors), 0)
+}
+
+func TestReportUploadFailure(t *testing.T) {
+	plugin := Plugin{
+		Config: Config{
+			AccessToken: "test-access-token",
+			FilePath:    filepath.Join(t.TempDir(), "missing.txt"),
+			ErrorPolicy: ErrorPolicyWarn,
+			OutputFile:  filepath.Join(t.TempDir(), "report.json"),
+		},
+	}
+
+	assert.NilError(t, plugin.Exec())
+
+	report := readTestReport(t, plugin.Config.OutputFile)
+	assert.Equal(t, report.Mode, modeUploadFile)
+	assert.Assert(t, report.Success)
+	assert.Equal(t, len(report.Errors), 1)
+	assert.Equal(t, report.Errors[0].Operation, opUploadFile)
+	assert.Equal(t, report.Errors[0].Policy, ErrorPolicyWarn)
+	assert.Equal(t, len(report.Uploads), 1)
+	assert.Equal(t, report.Uploads[0].Path, plugin.Config.FilePath)
+	assert.Assert(t, strings.Contains(report.Uploads[0].Error, "no such file or directory"), report.Uploads[0].Error)
+}
+
+func TestReportSkipped(t *testing.T) {
+	fake := newFakeSlack(t)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	for number := 1; number <= 2; number++ {
+		plugin := getFakeSlackPlugin(fake)
+		plugin.Build.Number = number
+		plugin.Config.NotifyOnChange = true
+		plugin.Config.StateFile = stateFile
+		plugin.Config.OutputFile = filepath.Join(t.TempDir(), "report.json")
+		assert.NilError(t, plugin.Exec())
+
+		if number == 2 {
+			report := readTestReport(t, plugin.Config.OutputFile)
+			assert.Equal(t, report.Mode, modeMessage)
+			assert.Equal(t, report.Skipped, "status unchanged")
+		}
+	}
+
+	// Webhooks are the mode of skipped webhook notifications
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = "http://127.0.0.1:0"
+	plugin.Config.NotifyOnChange = true
+	plugin.Config.StateFile = stateFile
+	plugin.Build.Number = 3
+	plugin.Config.OutputFile = filepath.Join(t.TempDir(), "report.json")
+	assert.NilError(t, plugin.Exec())
+
+	report := readTestReport(t, plugin.Config.OutputFile)
+	assert.Equal(t, report.Mode, modeWebhook)
+	assert.Equal(t, report.Skipped, "status unchanged")
+}
+
+func TestReportFailedRun(t *testing.T) {
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = "http://127.0.0.1:0"
+	plugin.Config.Template = "{{#if}}"
+	plugin.Config.OutputFile = filepath.Join(t.TempDir(), "report.json")
+
+	err := plugin.Exec()
+	assert.Assert(t, err != nil)
+
+	report := readTestReport(t, plugin.Config.OutputFile)
+	assert.Assert(t, !report.Success)
+	assert.Equal(t, report.Error, err.Error())
+}
+
+func readTestReport(t *testing.T, path string) Report {
+	b, err := os.ReadFile(path)
+	assert.NilError(t, err)
+
+	var report Report
+	assert.NilError(t, json.Unmarshal(b, &report))
+	return report
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// scheduleResponse is the part of the chat.scheduleMessage response that
+// slack.Client.ScheduleMessage drops.
+type scheduleResponse struct {
+	slack.SlackResponse
+	Channel            string `json:"channel"`
+	ScheduledMessageID string `json:"scheduled_message_id"`
+	PostAt             int64  `json:"post_at"`
+}
+
+// parseSendAt reads an absolute RFC3339 time or a duration relative to now.
+func parseSendAt(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("send at time %s is in the past", s)
+		}
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid send at %q, must be an RFC3339 time or a duration", s)
+	}
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("send at duration %s must be positive", s)
+	}
+	return now.Add(d), nil
+}
+
+func (p Plugin) scheduleMessage(channel string, options []slack.MsgOption, postAt time.Time) error {
+	start := time.Now()
+	id, channelID, err := scheduleMessage(p.httpClient(), p.apiURL(), p.Config.AccessToken, channel, postAt, options)
+	if channelID == "" {
+		channelID = channel
+	}
+	p.report.addMessage(MessageRecord{
+		Timing:             timingSince(start),
+		Channel:            channelID,
+		ScheduledMessageID: id,
+		PostAt:             postAt.Unix(),
+		Error:              errorString(err),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule message: %w", err)
+	}
+
+	log.Printf("Message %s scheduled for %s", id, postAt.Format(time.RFC3339))
+
+	if err := WriteEnvToOutputFile("SCHEDULED_MESSAGE_ID", id); err != nil {
+		return fmt.Errorf("failed to write scheduled message id to output file: %w", err)
+	}
+	if err := WriteEnvToOutputFile("SCHEDULED_MESSAGE_CHANNEL", channelID); err != nil {
+		return fmt.Errorf("failed to write scheduled message channel to output file: %w", err)
+	}
+	return nil
+}
+
+func scheduleMessage(client *http.Client, apiURL, token, channel string, postAt time.Time, options []slack.MsgOption) (string, string, error) {
+	endpoint, values, err := slack.UnsafeApplyMsgOptions(token, channel, apiURL,
+		slack.MsgOptionSchedule(strconv.FormatInt(postAt.Unix(), 10)),
+		slack.MsgOptionCompose(options...),
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	res, err := client.PostForm(endpoint, values)
+	if err != nil {
+		return "", "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("slack server error: %s", res.Status)
+	}
+
+	var response scheduleResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return "", "", fmt.Errorf("could not decode response: %w", err)
+	}
+	if err := response.Err(); err != nil {
+		return "", "", err
+	}
+
+	return response.ScheduledMessageID, response.Channel, nil
+}
+
+// CancelScheduledMessage deletes a message previously scheduled with SendAt.
+// chat.deleteScheduledMessage only takes the channel ID that
+// chat.scheduleMessage returned.
+func (p Plugin) CancelScheduledMessage() error {
+	channelID := p.Config.ScheduledChannel
+	if channelID == "" {
+		channelID = p.Config.Channel
+	}
+	if !isChannelID(channelID) {
+		err := fmt.Errorf("cancelling a scheduled message needs the channel ID from SCHEDULED_MESSAGE_CHANNEL, not %q", channelID)
+		p.report.addMessage(MessageRecord{
+			Channel:            channelID,
+			ScheduledMessageID: p.Config.CancelScheduledID,
+			Error:              err.Error(),
+		})
+		return err
+	}
+
+	start := time.Now()
+	api := p.slackClient()
+	_, err := api.DeleteScheduledMessage(&slack.DeleteScheduledMessageParameters{
+		Channel:            channelID,
+		ScheduledMessageID: p.Config.CancelScheduledID,
+	})
+	p.report.addMessage(MessageRecord{
+		Timing:             timingSince(start),
+		Channel:            channelID,
+		ScheduledMessageID: p.Config.CancelScheduledID,
+		Error:              errorString(err),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled message %s: %w", p.Config.CancelScheduledID, err)
+	}
+
+	log.Printf("Scheduled message %s cancelled", p.Config.CancelScheduledID)
+	return nil
+}
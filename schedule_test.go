@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseSendAt(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		Value  string
+		Expect time.Time
+		Err    string
+	}{
+		"Absolute": {
+			Value:  "2024-01-02T09:00:00Z",
+			Expect: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+		},
+		"Relative": {
+			Value:  "90m",
+			Expect: now.Add(90 * time.Minute),
+		},
+		"Past": {
+			Value: "2023-12-31T09:00:00Z",
+			Err:   "in the past",
+		},
+		"Negative": {
+			Value: "-1h",
+			Err:   "must be positive",
+		},
+		"Invalid": {
+			Value: "tomorrow",
+			Err:   "invalid send at",
+		},
+	}
+
+	for name, testCase := range testCases {
+		got, err := parseSendAt(testCase.Value, now)
+		if testCase.Err != "" {
+			assert.ErrorContains(t, err, testCase.Err, name)
+			continue
+		}
+		assert.NilError(t, err, name)
+		assert.Assert(t, got.Equal(testCase.Expect), name)
+	}
+}
+
+func TestScheduleMessage(t *testing.T) {
+	postAt := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	var called bool
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.Equal(t, r.URL.Path, "/chat.scheduleMessage")
+		assert.NilError(t, r.ParseForm())
+		assert.Equal(t, r.PostForm.Get("channel"), "#builds")
+		assert.Equal(t, r.PostForm.Get("post_at"), "1704186000")
+		assert.Equal(t, r.PostForm.Get("text"), "deploy goes out")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C123","scheduled_message_id":"Q123","post_at":1704186000}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	id, channel, err := scheduleMessage(http.DefaultClient, server.URL+"/", "test-access-token", "#builds", postAt,
+		[]slack.MsgOption{slack.MsgOptionText("deploy goes out", false)})
+	assert.NilError(t, err)
+	assert.Assert(t, called)
+	assert.Equal(t, id, "Q123")
+	assert.Equal(t, channel, "C123")
+}
+
+func TestScheduleMessageError(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":false,"error":"time_in_past"}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	_, _, err := scheduleMessage(http.DefaultClient, server.URL+"/", "test-access-token", "#builds", time.Now(), nil)
+	assert.ErrorContains(t, err, "time_in_past")
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Defaults for fetching remote templates.
+const (
+	DefaultTemplateMaxSize = 1 << 20
+	DefaultTemplateTimeout = 10 * time.Second
+)
+
+type (
+	// templateLoader resolves template settings, which can be a URL, a git
+	// or workspace source, a file or the template itself, to their contents.
+	templateLoader struct {
+		client  *http.Client
+		timeout time.Duration
+		maxSize int64
+		// Headers sent to template hosts, for private template repositories
+		headers map[string]string
+		// Directory remote templates are cached in, empty to disable caching
+		cacheDir string
+		// SHA-256 checksums remote templates must match, by source
+		checksums map[string]string
+		// Directory workspace:// sources are relative to
+		workspace string
+		// Fail on values that look like paths but aren't files
+		strict bool
+	}
+
+	// cachedTemplate is the metadata stored next to a cached template.
+	cachedTemplate struct {
+		URL          string `json:"url"`
+		ETag         string `json:"etag,omitempty"`
+		LastModified string `json:"last_modified,omitempty"`
+	}
+
+	// templateStatusError is an unexpected response from a template host.
+	templateStatusError struct {
+		URL    string
+		Status string
+		Code   int
+	}
+)
+
+func (e templateStatusError) Error() string {
+	return fmt.Sprintf("template server returned %s for %s", e.Status, e.URL)
+}
+
+// templateLoader returns the loader for the template settings of Config.
+func (p Plugin) templateLoader() (templateLoader, error) {
+	headers, err := parsePairs(p.Config.TemplateHeaders)
+	if err != nil {
+		return templateLoader{}, fmt.Errorf("invalid template headers: %w", err)
+	}
+
+	loader := templateLoader{
+		client:    p.httpClient(),
+		timeout:   p.Config.TemplateTimeout,
+		maxSize:   p.Config.TemplateMaxSize,
+		headers:   headers,
+		cacheDir:  p.Config.TemplateCacheDir,
+		workspace: droneWorkspace(),
+		strict:    p.Config.TemplateStrict,
+	}
+	if loader.maxSize <= 0 {
+		loader.maxSize = DefaultTemplateMaxSize
+	}
+	if p.Config.TemplateSHA256 != "" {
+		loader.checksums = map[string]string{p.Config.Template: p.Config.TemplateSHA256}
+	}
+	return loader, nil
+}
+
+// contents resolves a source to its contents. Sources can be explicit,
+// like file:, url:, inline:, env:, git:: or workspace://, or be guessed as a
+// URL, an existing file or else the template itself.
+func (l templateLoader) contents(source string) (string, error) {
+	// Check for the empty string
+	if source == "" {
+		return source, nil
+	}
+
+	kind, value := splitSource(source)
+
+	var b []byte
+	var err error
+	switch kind {
+	case sourceInline:
+		return value, nil
+
+	case sourceEnv:
+		v, ok := os.LookupEnv(value)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", value)
+		}
+		return v, nil
+
+	case sourceURL:
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return "", fmt.Errorf("invalid template url %q, must be http or https", value)
+		}
+		b, err = l.fetch(value)
+
+	case sourceGit:
+		b, err = l.gitFile(value)
+
+	case sourceWorkspace:
+		var path string
+		if path, err = l.workspacePath(value); err == nil {
+			b, err = readTemplateFile(path)
+		}
+
+	case sourceFile:
+		b, err = readTemplateFile(value)
+
+	default:
+		// See if the string is referencing a file
+		if _, statErr := os.Stat(value); statErr == nil {
+			b, err = readTemplateFile(value)
+			break
+		}
+		if looksLikePath(value) {
+			if l.strict {
+				return "", fmt.Errorf("could not load file %s: no such file, use inline: for a literal template", value)
+			}
+			log.Printf("No file %s, using it as the template itself", value)
+		}
+
+		// Its a regular string
+		return value, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifyChecksum(b, l.checksums[source]); err != nil {
+		return "", fmt.Errorf("template %s: %w", source, err)
+	}
+	return string(b), nil
+}
+
+// Kinds of template sources.
+const (
+	sourceGuess     = ""
+	sourceFile      = "file"
+	sourceURL       = "url"
+	sourceInline    = "inline"
+	sourceEnv       = "env"
+	sourceGit       = "git"
+	sourceWorkspace = "workspace"
+)
+
+// splitSource returns the kind of a source and the value it refers to.
+func splitSource(source string) (string, string) {
+	switch {
+	case strings.HasPrefix(source, "inline:"):
+		return sourceInline, strings.TrimPrefix(source, "inline:")
+	case strings.HasPrefix(source, "env:"):
+		return sourceEnv, strings.TrimPrefix(source, "env:")
+	case strings.HasPrefix(source, "url:"):
+		return sourceURL, strings.TrimPrefix(source, "url:")
+	case strings.HasPrefix(source, gitSourcePrefix):
+		return sourceGit, strings.TrimPrefix(source, gitSourcePrefix)
+	case strings.HasPrefix(source, workspaceScheme+"://"):
+		return sourceWorkspace, strings.TrimPrefix(source, workspaceScheme+"://")
+	case strings.HasPrefix(source, "file://"):
+		if u, err := url.Parse(source); err == nil {
+			return sourceFile, u.Path
+		}
+	case strings.HasPrefix(source, "file:"):
+		return sourceFile, strings.TrimPrefix(source, "file:")
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		return sourceURL, source
+	}
+	return sourceGuess, source
+}
+
+// looksLikePath reports whether a value that isn't a file was probably
+// meant to be one, like templates/build.hbs, rather than a template.
+func looksLikePath(value string) bool {
+	if value == "" || strings.ContainsAny(value, " \t\n{}<>*") {
+		return false
+	}
+	if strings.ContainsAny(value, "/\\") {
+		return true
+	}
+	// An extension like .hbs or .json, not the full stop of a sentence
+	ext := strings.TrimPrefix(filepath.Ext(value), ".")
+	return ext != "" && len(ext) <= 5 && strings.Trim(ext, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
+
+func readTemplateFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load file %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// fetch downloads a remote template. With a cache directory the cached copy
+// is revalidated with its ETag, and used as is when the host is down.
+func (l templateLoader) fetch(rawURL string) ([]byte, error) {
+	cached, meta, cacheErr := l.readCache(rawURL)
+	if cacheErr != nil && !errors.Is(cacheErr, os.ErrNotExist) {
+		log.Printf("Ignoring template cache of %s: %v", rawURL, cacheErr)
+	}
+
+	b, fresh, err := l.download(rawURL, meta)
+	if err != nil {
+		var statusErr templateStatusError
+		hostDown := !errors.As(err, &statusErr) || statusErr.Code >= 500
+		if cached != nil && hostDown {
+			log.Printf("Using cached template, could not fetch %s: %v", rawURL, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if !fresh {
+		// Not modified since it was cached
+		return cached, nil
+	}
+	if err := l.writeCache(rawURL, b, meta); err != nil {
+		log.Printf("Could not cache template %s: %v", rawURL, err)
+	}
+	return b, nil
+}
+
+// download requests rawURL, conditionally on meta when the template is
+// cached. It returns false when the cached copy is still current and fills
+// in the validators of the response.
+func (l templateLoader) download(rawURL string, meta *cachedTemplate) ([]byte, bool, error) {
+	ctx := context.Background()
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid template url: %w", err)
+	}
+	for name, value := range l.headers {
+		req.Header.Set(name, value)
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not fetch template: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && meta.URL != "" {
+		return nil, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, false, templateStatusError{URL: rawURL, Status: res.Status, Code: res.StatusCode}
+	}
+	if res.ContentLength > l.maxSize {
+		return nil, false, fmt.Errorf("template %s is larger than %d bytes", rawURL, l.maxSize)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, l.maxSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read response: %w", err)
+	}
+	if int64(len(b)) > l.maxSize {
+		return nil, false, fmt.Errorf("template %s is larger than %d bytes", rawURL, l.maxSize)
+	}
+
+	meta.URL = rawURL
+	meta.ETag = res.Header.Get("ETag")
+	meta.LastModified = res.Header.Get("Last-Modified")
+	return b, true, nil
+}
+
+// cachePath returns the path of the cached template of rawURL, and of its
+// metadata.
+func (l templateLoader) cachePath(rawURL string) (string, string) {
+	sum := sha256.Sum256([]byte(rawURL))
+	name := filepath.Join(l.cacheDir, hex.EncodeToString(sum[:]))
+	return name + ".tmpl", name + ".json"
+}
+
+// readCache returns the cached template of rawURL. The metadata is never
+// nil, so it can be filled in by a download.
+func (l templateLoader) readCache(rawURL string) ([]byte, *cachedTemplate, error) {
+	meta := &cachedTemplate{}
+	if l.cacheDir == "" {
+		return nil, meta, os.ErrNotExist
+	}
+
+	bodyPath, metaPath := l.cachePath(rawURL)
+	m, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, meta, err
+	}
+	var cached cachedTemplate
+	if err := json.Unmarshal(m, &cached); err != nil {
+		return nil, meta, fmt.Errorf("invalid metadata: %w", err)
+	}
+	b, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, meta, err
+	}
+
+	*meta = cached
+	return b, meta, nil
+}
+
+func (l templateLoader) writeCache(rawURL string, b []byte, meta *cachedTemplate) error {
+	if l.cacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(l.cacheDir, 0755); err != nil {
+		return err
+	}
+
+	m, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	bodyPath, metaPath := l.cachePath(rawURL)
+	if err := writeFileAtomic(bodyPath, b); err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, m)
+}
+
+// writeFileAtomic replaces name with b so concurrent builds sharing a cache
+// never read a partial file.
+func writeFileAtomic(name string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// checkTemplateSHA256 validates the checksum of the template, which can
+// only be enforced for the sources that are fetched: URLs and git.
+func checkTemplateSHA256(template, checksum string) error {
+	sum := strings.TrimPrefix(checksum, "sha256:")
+	if sum == "" {
+		return nil
+	}
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return fmt.Errorf("invalid template sha256 %q, must be 64 hex characters", checksum)
+	}
+	if kind, _ := splitSource(template); kind != sourceURL && kind != sourceGit {
+		return fmt.Errorf("template sha256 only pins a url or git:: template, not %q", template)
+	}
+	return nil
+}
+
+// verifyChecksum checks b against a hex SHA-256 checksum, when there is one.
+func verifyChecksum(b []byte, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+	sum := sha256.Sum256(b)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, strings.TrimPrefix(checksum, "sha256:")) {
+		return fmt.Errorf("checksum mismatch, got sha256:%s", actual)
+	}
+	return nil
+}
+
+// Prefixes of template sources in git repositories and the workspace.
+const (
+	gitSourcePrefix = "git::"
+	workspaceScheme = "workspace"
+)
+
+// gitFile reads a file from a git repository. The source has the form
+// <repository>//<path>[?ref=<ref>], where the repository is a local clone
+// or a URL go-git can clone, and ref a branch, tag or commit that defaults
+// to HEAD.
+func (l templateLoader) gitFile(source string) ([]byte, error) {
+	repoURL, path, ref, err := parseGitSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo *git.Repository
+	if info, statErr := os.Stat(repoURL); statErr == nil && info.IsDir() {
+		repo, err = git.PlainOpenWithOptions(repoURL, &git.PlainOpenOptions{DetectDotGit: true})
+	} else {
+		repo, err = l.cloneGitRepo(repoURL, ref)
+		if err == nil && !plumbing.IsHash(ref) {
+			// The clone checked out the ref
+			ref = "HEAD"
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open template repository %s: %w", repoURL, err)
+	}
+
+	hash, err := resolveGitRef(repo, ref)
+	if err != nil {
+		return nil, fmt.Errorf("template repository %s: %w", repoURL, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("template repository %s: %w", repoURL, err)
+	}
+	file, err := commit.File(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s at %s from %s: %w", path, ref, repoURL, err)
+	}
+	if file.Size > l.maxSize {
+		return nil, fmt.Errorf("template %s is larger than %d bytes", path, l.maxSize)
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s from %s: %w", path, repoURL, err)
+	}
+	return []byte(contents), nil
+}
+
+// gitProtocolsMu serializes clones, which swap the HTTP transports of
+// go-git's protocol registry.
+var gitProtocolsMu sync.Mutex
+
+// cloneGitRepo makes a shallow clone of ref without tags. A branch or tag
+// only fetches its last commit. A commit can't be fetched on its own, so it
+// clones the history of every branch.
+func (l templateLoader) cloneGitRepo(repoURL, ref string) (*git.Repository, error) {
+	// Clone through the plugin's HTTP client so proxy and CA settings apply,
+	// and put back the transports go-git had before
+	gitProtocolsMu.Lock()
+	defer gitProtocolsMu.Unlock()
+	for _, scheme := range []string{"http", "https"} {
+		previous := gitclient.Protocols[scheme]
+		gitclient.InstallProtocol(scheme, githttp.NewClient(l.client))
+		defer gitclient.InstallProtocol(scheme, previous)
+	}
+
+	if plumbing.IsHash(ref) {
+		return git.Clone(memory.NewStorage(), nil, &git.CloneOptions{URL: repoURL, Tags: git.NoTags})
+	}
+
+	var names []plumbing.ReferenceName
+	switch {
+	case ref == "HEAD":
+		names = []plumbing.ReferenceName{plumbing.HEAD}
+	case strings.HasPrefix(ref, "refs/"):
+		names = []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	default:
+		names = []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+
+	var err error
+	for _, name := range names {
+		var repo *git.Repository
+		repo, err = git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
+			URL:           repoURL,
+			ReferenceName: name,
+			SingleBranch:  true,
+			Depth:         1,
+			Tags:          git.NoTags,
+		})
+		if err == nil {
+			return repo, nil
+		}
+		if !errors.Is(err, git.NoMatchingRefSpecError{}) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("could not resolve ref %s: %w", ref, err)
+}
+
+// parseGitSource splits <repository>//<path>[?ref=<ref>].
+func parseGitSource(source string) (string, string, string, error) {
+	ref := "HEAD"
+	if i := strings.LastIndex(source, "?"); i >= 0 {
+		query, err := url.ParseQuery(source[i+1:])
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid git template source %q: %w", source, err)
+		}
+		if r := query.Get("ref"); r != "" {
+			ref = r
+		}
+		source = source[:i]
+	}
+
+	// Skip the // of the URL scheme
+	start := 0
+	if i := strings.Index(source, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	i := strings.Index(source[start:], "//")
+	if i < 0 {
+		return "", "", "", fmt.Errorf("invalid git template source %q, must be git::<repository>//<path>", source)
+	}
+	repoURL, path := source[:start+i], strings.Trim(source[start+i+2:], "/")
+	if repoURL == "" || path == "" {
+		return "", "", "", fmt.Errorf("invalid git template source %q, must be git::<repository>//<path>", source)
+	}
+	return repoURL, path, ref, nil
+}
+
+// resolveGitRef resolves a branch, tag or commit, including branches that
+// only exist as remote-tracking branches of a clone.
+func resolveGitRef(repo *git.Repository, ref string) (*plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err == nil {
+		return hash, nil
+	}
+	if hash, err := repo.ResolveRevision(plumbing.Revision("origin/" + ref)); err == nil {
+		return hash, nil
+	}
+	return nil, fmt.Errorf("could not resolve ref %s: %w", ref, err)
+}
+
+// workspacePath resolves a path relative to the workspace, which must not
+// point outside of it.
+func (l templateLoader) workspacePath(path string) (string, error) {
+	name := filepath.Join(l.workspace, filepath.FromSlash(path))
+	rel, err := filepath.Rel(l.workspace, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("template %s is outside of the workspace", path)
+	}
+	return name, nil
+}
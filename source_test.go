@@ -0,0 +1,352 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	"gotest.tools/v3/assert"
+)
+
+func TestTemplateFetchStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	_, err := templateMessage(server.URL+"/missing.hbs", plugin)
+	assert.ErrorContains(t, err, "template server returned 404 Not Found")
+}
+
+func TestTemplateFetchMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Without a Content-Length the limit applies while reading
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.TemplateMaxSize = 64
+	_, err := templateMessage(server.URL, plugin)
+	assert.ErrorContains(t, err, "larger than 64 bytes")
+
+	plugin.Config.TemplateMaxSize = 100
+	msg, err := templateMessage(server.URL, plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, len(msg), 100)
+}
+
+func TestTemplateFetchTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.TemplateTimeout = 50 * time.Millisecond
+	_, err := templateMessage(server.URL, plugin)
+	assert.ErrorContains(t, err, "context deadline exceeded")
+}
+
+func TestTemplateFetchHeadersAndChecksum(t *testing.T) {
+	body := "{{build.status}}"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.Template = server.URL + "/template.hbs"
+	_, err := templateMessage(plugin.Config.Template, plugin)
+	assert.ErrorContains(t, err, "401 Unauthorized")
+
+	plugin.Config.TemplateHeaders = "Authorization=token secret"
+	sum := sha256.Sum256([]byte(body))
+	plugin.Config.TemplateSHA256 = "sha256:" + hex.EncodeToString(sum[:])
+	msg, err := templateMessage(plugin.Config.Template, plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "success")
+
+	body = "{{build.status}} tampered"
+	_, err = templateMessage(plugin.Config.Template, plugin)
+	assert.ErrorContains(t, err, "checksum mismatch")
+
+	// The pin only applies to the template it was set for
+	_, err = templateMessage(server.URL+"/fallback.hbs", plugin)
+	assert.NilError(t, err)
+}
+
+func TestCheckTemplateSHA256(t *testing.T) {
+	sum := "sha256:" + strings.Repeat("ab", sha256.Size)
+	assert.NilError(t, checkTemplateSHA256("https://example.com/build.hbs", sum))
+	assert.NilError(t, checkTemplateSHA256("url:https://example.com/build.hbs", sum))
+	assert.NilError(t, checkTemplateSHA256("git::https://github.com/acme/ci-templates.git//build.hbs", sum))
+	assert.NilError(t, checkTemplateSHA256("inline:{{build.status}}", ""))
+
+	assert.ErrorContains(t, checkTemplateSHA256("https://example.com/build.hbs", "sha256:abc"), "must be 64 hex characters")
+	// Nothing is fetched for these, so there is nothing to pin
+	for _, template := range []string{"", "inline:{{build.status}}", "env:TEMPLATE", "file:build.hbs", "workspace://build.hbs", "build.hbs"} {
+		assert.ErrorContains(t, checkTemplateSHA256(template, sum), "only pins a url or git:: template", template)
+	}
+}
+
+func TestTemplateFetchCache(t *testing.T) {
+	var requests, notModified int
+	down := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if down {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("{{build.status}} cached"))
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.TemplateCacheDir = t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		msg, err := templateMessage(server.URL, plugin)
+		assert.NilError(t, err)
+		assert.Equal(t, msg, "success cached")
+	}
+	assert.Equal(t, requests, 2)
+	assert.Equal(t, notModified, 1)
+
+	// The cached copy is used while the host is down
+	down = true
+	msg, err := templateMessage(server.URL, plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "success cached")
+
+	// Without a cache the outage fails the build
+	plugin.Config.TemplateCacheDir = ""
+	_, err = templateMessage(server.URL, plugin)
+	assert.ErrorContains(t, err, "502 Bad Gateway")
+}
+
+// newTemplateRepo creates a repository whose templates/build.hbs changes
+// after the v1 tag.
+func newTemplateRepo(t *testing.T) string {
+	t.Helper()
+
+	fixture := newGitFixture(t)
+	fixture.commit("octocat@github.com", "v1", map[string]string{"templates/build.hbs": "v1: {{build.status}}"})
+	fixture.tag("v1")
+	fixture.commit("octocat@github.com", "v2", map[string]string{"templates/build.hbs": "v2: {{build.status}}"})
+	return fixture.Dir
+}
+
+func TestTemplateGitSource(t *testing.T) {
+	dir := newTemplateRepo(t)
+	plugin := getTestPlugin()
+
+	msg, err := templateMessage("git::"+dir+"//templates/build.hbs", plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "v2: success")
+
+	msg, err = templateMessage("git::"+dir+"//templates/build.hbs?ref=v1", plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "v1: success")
+
+	_, err = templateMessage("git::"+dir+"//templates/missing.hbs", plugin)
+	assert.ErrorContains(t, err, "could not load templates/missing.hbs")
+
+	_, err = templateMessage("git::"+dir+"//templates/build.hbs?ref=v3", plugin)
+	assert.ErrorContains(t, err, "could not resolve ref v3")
+}
+
+func TestTemplateGitSourceClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("cloning file:// repositories needs git")
+	}
+	dir := newTemplateRepo(t)
+
+	https := gitclient.Protocols["https"]
+
+	msg, err := templateMessage("git::file://"+dir+"//templates/build.hbs?ref=v1", getTestPlugin())
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "v1: success")
+
+	msg, err = templateMessage("git::file://"+dir+"//templates/build.hbs", getTestPlugin())
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "v2: success")
+
+	_, err = templateMessage("git::file://"+dir+"//templates/build.hbs?ref=v3", getTestPlugin())
+	assert.ErrorContains(t, err, "could not resolve ref v3")
+
+	// Clones only fetch the ref
+	loader, err := getTestPlugin().templateLoader()
+	assert.NilError(t, err)
+	repo, err := loader.cloneGitRepo("file://"+dir, "HEAD")
+	assert.NilError(t, err)
+	tags, err := repo.Tags()
+	assert.NilError(t, err)
+	assert.Equal(t, countReferences(t, tags), 0)
+	commits, err := repo.CommitObjects()
+	assert.NilError(t, err)
+	assert.Equal(t, countCommits(t, commits), 1)
+
+	// go-git's transports are put back
+	assert.Equal(t, gitclient.Protocols["https"], https)
+}
+
+func countReferences(t *testing.T, iter storer.ReferenceIter) int {
+	n := 0
+	assert.NilError(t, iter.ForEach(func(*plumbing.Reference) error {
+		n++
+		return nil
+	}))
+	return n
+}
+
+func countCommits(t *testing.T, iter object.CommitIter) int {
+	n := 0
+	assert.NilError(t, iter.ForEach(func(*object.Commit) error {
+		n++
+		return nil
+	}))
+	return n
+}
+
+func TestParseGitSource(t *testing.T) {
+	tests := []struct {
+		source, repo, path, ref string
+	}{
+		{"https://github.com/org/templates.git//slack/build.hbs?ref=v1", "https://github.com/org/templates.git", "slack/build.hbs", "v1"},
+		{"/src/templates//build.hbs", "/src/templates", "build.hbs", "HEAD"},
+	}
+	for _, test := range tests {
+		repo, path, ref, err := parseGitSource(test.source)
+		assert.NilError(t, err)
+		assert.Equal(t, repo, test.repo)
+		assert.Equal(t, path, test.path)
+		assert.Equal(t, ref, test.ref)
+	}
+
+	_, _, _, err := parseGitSource("https://github.com/org/templates.git")
+	assert.ErrorContains(t, err, "must be git::<repository>//<path>")
+}
+
+func TestTemplateWorkspaceSource(t *testing.T) {
+	workspace := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(workspace, "build.hbs"), []byte("{{build.status}} from the workspace"), 0644))
+	t.Setenv("DRONE_WORKSPACE", workspace)
+	plugin := getTestPlugin()
+
+	msg, err := templateMessage("workspace://build.hbs", plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "success from the workspace")
+
+	// A misspelled path is an error, not the message
+	_, err = templateMessage("workspace://biuld.hbs", plugin)
+	assert.ErrorContains(t, err, "could not load file")
+
+	_, err = templateMessage("workspace://../secret", plugin)
+	assert.ErrorContains(t, err, "outside of the workspace")
+}
+
+func TestTemplateExplicitSources(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "build.hbs")
+	assert.NilError(t, os.WriteFile(path, []byte("{{build.status}} from a file"), 0644))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{{build.status}} from a url"))
+	}))
+	defer server.Close()
+	t.Setenv("SLACK_TEMPLATE", "{{build.status}} from the environment")
+
+	tests := []struct {
+		source, want string
+	}{
+		{"file:" + path, "success from a file"},
+		{"file://" + path, "success from a file"},
+		{path, "success from a file"},
+		{"url:" + server.URL, "success from a url"},
+		{server.URL, "success from a url"},
+		{"env:SLACK_TEMPLATE", "success from the environment"},
+		{"inline:" + path, path},
+		{"inline:{{build.status}}", "success"},
+		{"{{build.status}} as is", "success as is"},
+	}
+	plugin := getTestPlugin()
+	for _, test := range tests {
+		msg, err := templateMessage(test.source, plugin)
+		assert.NilError(t, err, test.source)
+		assert.Equal(t, msg, test.want, test.source)
+	}
+
+	_, err := templateMessage("file:"+filepath.Join(dir, "missing.hbs"), plugin)
+	assert.ErrorContains(t, err, "could not load file")
+	_, err = templateMessage("env:SLACK_MISSING_TEMPLATE", plugin)
+	assert.ErrorContains(t, err, "SLACK_MISSING_TEMPLATE is not set")
+	_, err = templateMessage("url:ftp://example.com/build.hbs", plugin)
+	assert.ErrorContains(t, err, "must be http or https")
+}
+
+func TestTemplateStrict(t *testing.T) {
+	plugin := getTestPlugin()
+
+	// A misspelled path is posted as is unless strict
+	msg, err := templateMessage("templates/biuld.hbs", plugin)
+	assert.NilError(t, err)
+	assert.Equal(t, msg, "templates/biuld.hbs")
+
+	plugin.Config.TemplateStrict = true
+	_, err = templateMessage("templates/biuld.hbs", plugin)
+	assert.ErrorContains(t, err, "could not load file templates/biuld.hbs")
+	_, err = templateMessage("build.hbs", plugin)
+	assert.ErrorContains(t, err, "could not load file build.hbs")
+
+	for _, text := range []string{"Build finished.", "*{{build.status}}* on {{build.branch}}", "Deployed", "inline:build.hbs"} {
+		_, err := templateMessage(text, plugin)
+		assert.NilError(t, err, text)
+	}
+}
+
+func TestCustomBlockSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.json")
+	assert.NilError(t, os.WriteFile(path, []byte(`{"blocks":[{"type":"section","text":{"type":"mrkdwn","text":"from a file"}}]}`), 0644))
+
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	plugin := getTestPlugin()
+	plugin.Config.Webhook = server.URL
+	plugin.Config.CustomBlock = "file:" + path
+	assert.NilError(t, plugin.Exec())
+	assert.Assert(t, strings.Contains(body, `"text":"from a file"`), body)
+
+	plugin.Config.CustomBlock = "file:" + path + ".missing"
+	assert.ErrorContains(t, plugin.Exec(), "could not read custom block")
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Pseudo-statuses set in Build.Change when the build status flips.
+const (
+	StatusFixed  = "fixed"
+	StatusBroken = "broken"
+)
+
+// maxHistoryScan bounds how many messages are read to find the previous build.
+const maxHistoryScan = 1000
+
+type (
+	// State is persisted between builds in Config.StateFile.
+	State struct {
+		Builds       map[string]BuildState       `json:"builds"`
+		Suppressions map[string]SuppressionState `json:"suppressions"`
+	}
+
+	// BuildState is the last known status of a repository branch.
+	BuildState struct {
+		Status  string    `json:"status"`
+		Number  int       `json:"number"`
+		Updated time.Time `json:"updated"`
+	}
+)
+
+// loadState reads the state file. A missing file is an empty state.
+func loadState(path string) (State, error) {
+	state := State{
+		Builds:       map[string]BuildState{},
+		Suppressions: map[string]SuppressionState{},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("could not read state file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &state); err != nil {
+		return state, fmt.Errorf("could not parse state file %s: %w", path, err)
+	}
+	if state.Builds == nil {
+		state.Builds = map[string]BuildState{}
+	}
+	if state.Suppressions == nil {
+		state.Suppressions = map[string]SuppressionState{}
+	}
+	return state, nil
+}
+
+// save writes the state file through a rename so concurrent builds never read
+// a partial file.
+func (s State) save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".drone-slack-state-*")
+	if err != nil {
+		return fmt.Errorf("could not write state file %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write state file %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write state file %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write state file %s: %w", path, err)
+	}
+	return nil
+}
+
+// stateKey identifies the branch whose status is tracked.
+func (p Plugin) stateKey() string {
+	return p.Repo.fullName() + "@" + p.Build.Branch
+}
+
+// statusChange compares the color() buckets of two statuses and returns
+// StatusFixed, StatusBroken or an empty string.
+func statusChange(previous, current string) string {
+	prev, cur := color(Build{Status: previous}), color(Build{Status: current})
+	switch {
+	case prev == "danger" && cur == "good":
+		return StatusFixed
+	case prev == "good" && cur == "danger":
+		return StatusBroken
+	}
+	return ""
+}
+
+// detectStatusChange looks up the status of the previous build, sets
+// Build.PreviousStatus and Build.Change, and reports whether the status
+// changed enough to notify.
+func (p *Plugin) detectStatusChange() (bool, error) {
+	previous, err := p.previousStatus()
+	if err != nil {
+		return true, err
+	}
+
+	p.Build.PreviousStatus = previous
+	p.Build.Change = statusChange(previous, p.Build.Status)
+
+	change := p.Build.Change
+	if change == "" {
+		change = "unchanged"
+	}
+	if err := WriteEnvToOutputFile("STATUS_CHANGE", change); err != nil {
+		log.Println("Failed to write status change to output file: ", err)
+	}
+
+	if previous == "" {
+		log.Println("No previous build status found, notifying")
+		return true, nil
+	}
+	if color(Build{Status: previous}) == color(Build{Status: p.Build.Status}) {
+		log.Printf("Build status %s unchanged since the previous build, skipping notification", p.Build.Status)
+		p.report.skip("status unchanged")
+		return false, nil
+	}
+	return true, nil
+}
+
+// previousStatus reads the status of the previous build from the state file,
+// or from the last message the bot posted for the branch.
+func (p Plugin) previousStatus() (string, error) {
+	if p.Config.StateFile != "" {
+		state, err := loadState(p.Config.StateFile)
+		if err != nil {
+			return "", err
+		}
+		return state.Builds[p.stateKey()].Status, nil
+	}
+
+	if p.Config.AccessToken == "" || p.Config.Channel == "" {
+		return "", errors.New("detecting status changes needs a state file, or an access token and channel")
+	}
+	channelID, err := p.historyChannel()
+	if err != nil {
+		return "", err
+	}
+
+	api := p.slackClient()
+	auth, err := api.AuthTest()
+	if err != nil {
+		return "", fmt.Errorf("failed to authenticate using access token: %w", err)
+	}
+	return previousStatusFromHistory(api, channelID, auth.BotID, p.Repo.fullName(), p.Build.Branch, p.Build.Number)
+}
+
+// recordStatus stores the status of the build in the state file once its
+// notification is sent, so a failed send is retried by the next build. A
+// build older than the stored one doesn't overwrite it.
+func (p Plugin) recordStatus() error {
+	if !p.Config.NotifyOnChange || p.Config.StateFile == "" {
+		return nil
+	}
+
+	state, err := loadState(p.Config.StateFile)
+	if err != nil {
+		return err
+	}
+
+	key := p.stateKey()
+	if previous, ok := state.Builds[key]; ok && previous.Number > p.Build.Number {
+		log.Printf("State file holds newer build %d, not recording build %d", previous.Number, p.Build.Number)
+		return nil
+	}
+
+	state.Builds[key] = BuildState{
+		Status:  p.Build.Status,
+		Number:  p.Build.Number,
+		Updated: time.Now(),
+	}
+	return state.save(p.Config.StateFile)
+}
+
+// previousStatusFromHistory returns the status in the metadata of the newest
+// message the bot posted for an earlier build of the branch.
+func previousStatusFromHistory(api *slack.Client, channelID, botID, repo, branch string, number int) (string, error) {
+	var status string
+	scanned := 0
+	err := scanHistory(api, channelID, time.Time{}, func(msg slack.Message) bool {
+		scanned++
+		if scanned > maxHistoryScan {
+			return false
+		}
+
+		payload := msg.Metadata.EventPayload
+		if msg.BotID != botID || payload == nil || payload[metadataRepo] != repo || payload[metadataBranch] != branch {
+			return true
+		}
+
+		n, ok := buildNumber(payload)
+		if !ok || n >= number {
+			return true
+		}
+
+		status, _ = payload[metadataStatus].(string)
+		return false
+	})
+	return status, err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestStatusChange(t *testing.T) {
+	testCases := []struct {
+		Previous string
+		Current  string
+		Expect   string
+	}{
+		{"success", "failure", StatusBroken},
+		{"success", "killed", StatusBroken},
+		{"error", "success", StatusFixed},
+		{"success", "success", ""},
+		{"failure", "error", ""},
+		{"", "failure", ""},
+		{"running", "success", ""},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, statusChange(testCase.Previous, testCase.Current), testCase.Expect,
+			"%s -> %s", testCase.Previous, testCase.Current)
+	}
+}
+
+func TestColorPseudoStatus(t *testing.T) {
+	assert.Equal(t, color(Build{Status: "success", Change: StatusFixed}), "good")
+	assert.Equal(t, color(Build{Status: "failure", Change: StatusBroken}), "danger")
+	assert.Equal(t, color(Build{Status: StatusFixed}), "good")
+	assert.Equal(t, color(Build{Status: StatusBroken}), "danger")
+}
+
+func TestDetectStatusChangeStateFile(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	steps := []struct {
+		Number int
+		Status string
+		Notify bool
+		Change string
+	}{
+		{Number: 1, Status: "success", Notify: true},
+		{Number: 2, Status: "success", Notify: false},
+		{Number: 3, Status: "failure", Notify: true, Change: StatusBroken},
+		{Number: 4, Status: "error", Notify: false},
+		{Number: 5, Status: "success", Notify: true, Change: StatusFixed},
+	}
+
+	for _, step := range steps {
+		plugin := getTestPlugin()
+		plugin.Build.Number = step.Number
+		plugin.Build.Status = step.Status
+		plugin.Config.StateFile = stateFile
+
+		notify, err := plugin.detectStatusChange()
+		assert.NilError(t, err, "build %d", step.Number)
+		assert.Equal(t, notify, step.Notify, "build %d", step.Number)
+		assert.Equal(t, plugin.Build.Change, step.Change, "build %d", step.Number)
+		// Only sent notifications are recorded
+		if notify {
+			plugin.Config.NotifyOnChange = true
+			assert.NilError(t, plugin.recordStatus(), "build %d", step.Number)
+		}
+	}
+
+	state, err := loadState(stateFile)
+	assert.NilError(t, err)
+	assert.Equal(t, state.Builds["octocat/hello-world@master"].Number, 5)
+	assert.Equal(t, state.Builds["octocat/hello-world@master"].Status, "success")
+
+	// Out of order builds don't overwrite newer state
+	plugin := getTestPlugin()
+	plugin.Build.Number = 4
+	plugin.Build.Status = "failure"
+	plugin.Config.NotifyOnChange = true
+	plugin.Config.StateFile = stateFile
+	assert.NilError(t, plugin.recordStatus())
+
+	state, err = loadState(stateFile)
+	assert.NilError(t, err)
+	assert.Equal(t, state.Builds["octocat/hello-world@master"].Number, 5)
+}
+
+func TestPreviousStatusFromHistory(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"messages":[
+			{"ts":"400.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","branch":"master","build_number":7,"status":"success"}}},
+			{"ts":"300.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","branch":"feature","build_number":6,"status":"success"}}},
+			{"ts":"200.000001","bot_id":"B2","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","branch":"master","build_number":5,"status":"success"}}},
+			{"ts":"100.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"repo":"octocat/hello-world","branch":"master","build_number":4,"status":"failure"}}}
+		]}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	api := slack.New("test-access-token", slack.OptionAPIURL(server.URL+"/"))
+
+	status, err := previousStatusFromHistory(api, "C123", "B1", "octocat/hello-world", "master", 7)
+	assert.NilError(t, err)
+	assert.Equal(t, status, "failure")
+
+	status, err = previousStatusFromHistory(api, "C123", "B1", "octocat/hello-world", "master", 8)
+	assert.NilError(t, err)
+	assert.Equal(t, status, "success")
+
+	status, err = previousStatusFromHistory(api, "C123", "B1", "octocat/hello-world", "release", 8)
+	assert.NilError(t, err)
+	assert.Equal(t, status, "")
+}
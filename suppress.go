@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/drone/drone-template-lib/template"
+	"github.com/slack-go/slack"
+)
+
+// Modes of Config.SuppressMode.
+const (
+	SuppressSkip   = "skip"
+	SuppressThread = "thread"
+)
+
+// DefaultSuppressKey groups notifications by repository, branch and status.
+const DefaultSuppressKey = "{{repo.owner}}/{{repo.name}}/{{build.branch}}/{{build.status}}"
+
+// suppressionRetention is how long expired suppression windows are kept in
+// the state file before being pruned.
+const suppressionRetention = 30 * 24 * time.Hour
+
+// Keys of the metadata payload used to find repeated notifications.
+const (
+	metadataSuppressKey   = "suppress_key"
+	metadataSuppressCount = "suppressed_count"
+)
+
+// SuppressionState is the first notification of a suppression window.
+type SuppressionState struct {
+	First   time.Time `json:"first"`
+	Channel string    `json:"channel,omitempty"`
+	Ts      string    `json:"ts,omitempty"`
+	ReplyTs string    `json:"reply_ts,omitempty"`
+	Count   int       `json:"count"`
+}
+
+// checkSuppression renders the suppression key and reports whether an
+// identical notification was already sent within the window. Repeats are
+// counted in a thread reply under the first notification in thread mode.
+func (p *Plugin) checkSuppression() (bool, error) {
+	keyTemplate := p.Config.SuppressKey
+	if keyTemplate == "" {
+		keyTemplate = DefaultSuppressKey
+	}
+	key, err := template.RenderTrim(keyTemplate, *p)
+	if err != nil {
+		return false, fmt.Errorf("could not render suppress key: %w", err)
+	}
+	p.suppressKey = key
+
+	if p.Config.StateFile != "" {
+		return p.checkSuppressionState(key)
+	}
+
+	if p.Config.AccessToken == "" || p.Config.Channel == "" {
+		return false, errors.New("suppressing duplicates needs a state file, or an access token and channel")
+	}
+	return p.checkSuppressionHistory(key)
+}
+
+func (p Plugin) checkSuppressionState(key string) (bool, error) {
+	state, err := loadState(p.Config.StateFile)
+	if err != nil {
+		return false, err
+	}
+
+	entry, ok := state.Suppressions[key]
+	if !ok || time.Since(entry.First) >= p.Config.SuppressWindow {
+		// Recorded by recordSuppression once the notification is sent
+		return false, nil
+	}
+
+	entry.Count++
+	log.Printf("Suppressing repeated notification %s (%d repeats)", key, entry.Count)
+	p.report.skip("duplicate")
+
+	var threadErr error
+	if p.threadsSuppressed() && entry.Ts != "" {
+		api := p.slackClient()
+		entry.ReplyTs, threadErr = p.postSuppressedCount(api, entry.Channel, entry.Ts, entry.ReplyTs, entry.Count)
+	}
+
+	state.Suppressions[key] = entry
+	if err := state.save(p.Config.StateFile); err != nil {
+		return true, err
+	}
+	return true, threadErr
+}
+
+func (p Plugin) checkSuppressionHistory(key string) (bool, error) {
+	channelID, err := p.historyChannel()
+	if err != nil {
+		return false, err
+	}
+
+	api := p.slackClient()
+	auth, err := api.AuthTest()
+	if err != nil {
+		return false, fmt.Errorf("failed to authenticate using access token: %w", err)
+	}
+
+	var first *slack.Message
+	err = scanHistory(api, channelID, time.Now().Add(-p.Config.SuppressWindow), func(msg slack.Message) bool {
+		// History is newest first, so keep going to find the oldest
+		if msg.BotID == auth.BotID && msg.Metadata.EventPayload[metadataSuppressKey] == key {
+			first = &msg
+		}
+		return true
+	})
+	if err != nil || first == nil {
+		return false, err
+	}
+
+	p.report.skip("duplicate")
+	if !p.threadsSuppressed() {
+		log.Printf("Suppressing repeated notification %s", key)
+		return true, nil
+	}
+
+	count, replyTs, err := suppressedCount(api, channelID, first.Timestamp, auth.BotID, key)
+	if err != nil {
+		return true, err
+	}
+
+	count++
+	log.Printf("Suppressing repeated notification %s (%d repeats)", key, count)
+	_, err = p.postSuppressedCount(api, channelID, first.Timestamp, replyTs, count)
+	return true, err
+}
+
+// suppressedCount reads the repeat counter from the bot's reply in the thread
+// of the first notification.
+func suppressedCount(api *slack.Client, channelID, ts, botID, key string) (int, string, error) {
+	params := &slack.GetConversationRepliesParameters{
+		ChannelID:          channelID,
+		Timestamp:          ts,
+		Limit:              historyPageSize,
+		IncludeAllMetadata: true,
+	}
+
+	for {
+		replies, hasMore, cursor, err := api.GetConversationReplies(params)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to read thread replies: %w", err)
+		}
+
+		for _, reply := range replies {
+			payload := reply.Metadata.EventPayload
+			if reply.Timestamp == ts || reply.BotID != botID || payload[metadataSuppressKey] != key {
+				continue
+			}
+			count, _ := payload[metadataSuppressCount].(float64)
+			return int(count), reply.Timestamp, nil
+		}
+
+		if !hasMore || cursor == "" {
+			return 0, "", nil
+		}
+		params.Cursor = cursor
+	}
+}
+
+func (p Plugin) threadsSuppressed() bool {
+	return p.Config.SuppressMode == SuppressThread && p.Config.AccessToken != ""
+}
+
+// postSuppressedCount posts the repeat counter in the thread of parentTs, or
+// updates the existing counter reply, and returns the reply ts.
+func (p Plugin) postSuppressedCount(api *slack.Client, channelID, parentTs, replyTs string, count int) (string, error) {
+	times := "times"
+	if count == 1 {
+		times = "time"
+	}
+
+	eventType := p.Config.MetadataEventType
+	if eventType == "" {
+		eventType = DefaultMetadataEventType
+	}
+
+	options := []slack.MsgOption{
+		slack.MsgOptionText(fmt.Sprintf("%s %d more %s", statusVerb(p.Build.Status), count, times), false),
+		slack.MsgOptionMetadata(slack.SlackMetadata{
+			EventType: eventType,
+			EventPayload: map[string]interface{}{
+				metadataSuppressKey:   p.suppressKey,
+				metadataSuppressCount: count,
+			},
+		}),
+	}
+
+	start := time.Now()
+	updating := replyTs != ""
+	var err error
+	if !updating {
+		_, replyTs, err = api.PostMessage(channelID, append(options, slack.MsgOptionTS(parentTs))...)
+	} else {
+		_, _, _, err = api.UpdateMessage(channelID, replyTs, options...)
+	}
+	p.report.addMessage(MessageRecord{
+		Timing:    timingSince(start),
+		Channel:   channelID,
+		Timestamp: replyTs,
+		Updated:   updating,
+		Error:     errorString(err),
+	})
+	if err != nil {
+		return replyTs, fmt.Errorf("failed to post repeat count: %w", err)
+	}
+	return replyTs, nil
+}
+
+// recordSuppression starts a suppression window for the notification just
+// sent to channelID/ts.
+func (p Plugin) recordSuppression(channelID, ts string) error {
+	if p.Config.StateFile == "" || p.suppressKey == "" {
+		return nil
+	}
+
+	state, err := loadState(p.Config.StateFile)
+	if err != nil {
+		return err
+	}
+
+	for key, entry := range state.Suppressions {
+		if time.Since(entry.First) > suppressionRetention {
+			delete(state.Suppressions, key)
+		}
+	}
+
+	state.Suppressions[p.suppressKey] = SuppressionState{
+		First:   time.Now(),
+		Channel: channelID,
+		Ts:      ts,
+	}
+	return state.save(p.Config.StateFile)
+}
+
+// statusVerb describes a build status in a sentence like "failed 5 more times".
+func statusVerb(status string) string {
+	switch status {
+	case "success":
+		return "Succeeded"
+	case "failure":
+		return "Failed"
+	case "error":
+		return "Errored"
+	case "killed":
+		return "Killed"
+	default:
+		return "Repeated"
+	}
+}
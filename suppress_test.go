@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheckSuppressionStateFile(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	plugin := getTestPlugin()
+	plugin.Build.Status = "failure"
+	plugin.Config.StateFile = stateFile
+	plugin.Config.SuppressWindow = time.Hour
+
+	suppressed, err := plugin.checkSuppression()
+	assert.NilError(t, err)
+	assert.Assert(t, !suppressed)
+	assert.Equal(t, plugin.suppressKey, "octocat/hello-world/master/failure")
+	assert.NilError(t, plugin.recordSuppression("C123", "100.000001"))
+
+	for i := 1; i <= 3; i++ {
+		plugin := getTestPlugin()
+		plugin.Build.Status = "failure"
+		plugin.Config.StateFile = stateFile
+		plugin.Config.SuppressWindow = time.Hour
+
+		suppressed, err := plugin.checkSuppression()
+		assert.NilError(t, err)
+		assert.Assert(t, suppressed)
+	}
+
+	state, err := loadState(stateFile)
+	assert.NilError(t, err)
+	entry := state.Suppressions["octocat/hello-world/master/failure"]
+	assert.Equal(t, entry.Count, 3)
+	assert.Equal(t, entry.Ts, "100.000001")
+
+	// A different status has its own key
+	plugin = getTestPlugin()
+	plugin.Build.Status = "success"
+	plugin.Config.StateFile = stateFile
+	plugin.Config.SuppressWindow = time.Hour
+	suppressed, err = plugin.checkSuppression()
+	assert.NilError(t, err)
+	assert.Assert(t, !suppressed)
+
+	// The window expires
+	entry.First = time.Now().Add(-2 * time.Hour)
+	state.Suppressions["octocat/hello-world/master/failure"] = entry
+	assert.NilError(t, state.save(stateFile))
+
+	plugin = getTestPlugin()
+	plugin.Build.Status = "failure"
+	plugin.Config.StateFile = stateFile
+	plugin.Config.SuppressWindow = time.Hour
+	suppressed, err = plugin.checkSuppression()
+	assert.NilError(t, err)
+	assert.Assert(t, !suppressed)
+}
+
+func TestPostSuppressedCount(t *testing.T) {
+	var calls []string
+	var texts []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		calls = append(calls, r.URL.Path)
+		texts = append(texts, r.Form.Get("text"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C123","ts":"200.000001"}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	api := slack.New("test-access-token", slack.OptionAPIURL(server.URL+"/"))
+
+	plugin := getTestPlugin()
+	plugin.Build.Status = "failure"
+
+	replyTs, err := plugin.postSuppressedCount(api, "C123", "100.000001", "", 1)
+	assert.NilError(t, err)
+	assert.Equal(t, replyTs, "200.000001")
+
+	replyTs, err = plugin.postSuppressedCount(api, "C123", "100.000001", replyTs, 5)
+	assert.NilError(t, err)
+	assert.Equal(t, replyTs, "200.000001")
+
+	assert.DeepEqual(t, calls, []string{"/chat.postMessage", "/chat.update"})
+	assert.DeepEqual(t, texts, []string{"Failed 1 more time", "Failed 5 more times"})
+}
+
+func TestSuppressedCount(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"messages":[
+			{"ts":"100.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"suppress_key":"k"}}},
+			{"ts":"150.000001","user":"U1","text":"looking into it"},
+			{"ts":"200.000001","bot_id":"B1","metadata":{"event_type":"drone_build","event_payload":{"suppress_key":"k","suppressed_count":4}}}
+		]}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	api := slack.New("test-access-token", slack.OptionAPIURL(server.URL+"/"))
+
+	count, replyTs, err := suppressedCount(api, "C123", "100.000001", "B1", "k")
+	assert.NilError(t, err)
+	assert.Equal(t, count, 4)
+	assert.Equal(t, replyTs, "200.000001")
+
+	count, replyTs, err = suppressedCount(api, "C123", "100.000001", "B1", "other")
+	assert.NilError(t, err)
+	assert.Equal(t, count, 0)
+	assert.Equal(t, replyTs, "")
+}
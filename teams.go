@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/slack-go/slack"
+)
+
+// TransportTeams posts Adaptive Cards to a Microsoft Teams incoming webhook.
+const TransportTeams = "teams"
+
+const (
+	adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+	adaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	adaptiveCardVersion     = "1.4"
+)
+
+type (
+	// teamsMessage is the body of a Teams incoming webhook.
+	teamsMessage struct {
+		Type        string            `json:"type"`
+		Attachments []teamsAttachment `json:"attachments"`
+	}
+
+	teamsAttachment struct {
+		ContentType string       `json:"contentType"`
+		Content     adaptiveCard `json:"content"`
+	}
+
+	adaptiveCard struct {
+		Schema       string            `json:"$schema"`
+		Type         string            `json:"type"`
+		Version      string            `json:"version"`
+		FallbackText string            `json:"fallbackText,omitempty"`
+		Body         []cardElement     `json:"body"`
+		Actions      []cardAction      `json:"actions,omitempty"`
+		MSTeams      map[string]string `json:"msteams,omitempty"`
+	}
+
+	// cardElement covers the TextBlock, Container, FactSet and Image
+	// elements the plugin uses.
+	cardElement struct {
+		Type      string        `json:"type"`
+		Text      string        `json:"text,omitempty"`
+		Weight    string        `json:"weight,omitempty"`
+		Size      string        `json:"size,omitempty"`
+		IsSubtle  bool          `json:"isSubtle,omitempty"`
+		Wrap      bool          `json:"wrap,omitempty"`
+		Style     string        `json:"style,omitempty"`
+		Bleed     bool          `json:"bleed,omitempty"`
+		Separator bool          `json:"separator,omitempty"`
+		Items     []cardElement `json:"items,omitempty"`
+		Facts     []cardFact    `json:"facts,omitempty"`
+		URL       string        `json:"url,omitempty"`
+		AltText   string        `json:"altText,omitempty"`
+	}
+
+	cardFact struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+	}
+
+	cardAction struct {
+		Type  string `json:"type"`
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	}
+)
+
+// teamsTransport renders notifications as Adaptive Cards. Block Kit headers
+// become a header styled by the build color, section fields a fact set and
+// link buttons OpenUrl actions.
+type teamsTransport struct {
+	webhook string
+	client  *http.Client
+	// Slack user IDs to Teams display names
+	users map[string]string
+}
+
+func (t teamsTransport) Send(n Notification) error {
+	return postJSON(t.client, t.webhook, t.payload(n))
+}
+
+func (t teamsTransport) payload(n Notification) teamsMessage {
+	card := adaptiveCard{
+		Schema:       adaptiveCardSchema,
+		Type:         "AdaptiveCard",
+		Version:      adaptiveCardVersion,
+		FallbackText: t.text(n.Fallback),
+		MSTeams:      map[string]string{"width": "Full"},
+	}
+
+	header := cardElement{
+		Type:  "Container",
+		Style: accentStyle(n.Color),
+		Bleed: true,
+	}
+	if n.Text != "" {
+		header.Items = append(header.Items, cardElement{Type: "TextBlock", Text: t.text(n.Text), Wrap: true})
+	}
+
+	separator := false
+	for _, block := range n.Blocks {
+		switch block := block.(type) {
+		case *slack.HeaderBlock:
+			if block.Text != nil {
+				header.Items = append(header.Items, cardElement{
+					Type:   "TextBlock",
+					Text:   t.text(block.Text.Text),
+					Weight: "Bolder",
+					Size:   "Medium",
+					Wrap:   true,
+				})
+			}
+		case *slack.SectionBlock:
+			if block.Text != nil {
+				card.Body = append(card.Body, cardElement{
+					Type:      "TextBlock",
+					Text:      t.text(block.Text.Text),
+					Wrap:      true,
+					Separator: separator,
+				})
+				separator = false
+			}
+			if len(block.Fields) > 0 {
+				facts := cardElement{Type: "FactSet", Separator: separator}
+				for _, field := range block.Fields {
+					f := splitField(field.Text)
+					facts.Facts = append(facts.Facts, cardFact{Title: t.text(f.Title), Value: t.text(f.Value)})
+				}
+				card.Body = append(card.Body, facts)
+				separator = false
+			}
+		case *slack.ContextBlock:
+			for _, element := range block.ContextElements.Elements {
+				if text, ok := element.(*slack.TextBlockObject); ok {
+					card.Body = append(card.Body, cardElement{
+						Type:      "TextBlock",
+						Text:      t.text(text.Text),
+						Size:      "Small",
+						IsSubtle:  true,
+						Wrap:      true,
+						Separator: separator,
+					})
+					separator = false
+				}
+			}
+		case *slack.ImageBlock:
+			card.Body = append(card.Body, cardElement{Type: "Image", URL: block.ImageURL, AltText: block.AltText, Separator: separator})
+			separator = false
+		case *slack.DividerBlock:
+			separator = true
+		case *slack.ActionBlock:
+			for _, element := range block.Elements.ElementSet {
+				if button, ok := element.(*slack.ButtonBlockElement); ok && button.URL != "" && button.Text != nil {
+					card.Actions = append(card.Actions, cardAction{
+						Type:  "Action.OpenUrl",
+						Title: t.text(button.Text.Text),
+						URL:   button.URL,
+					})
+				}
+			}
+		}
+	}
+
+	if len(header.Items) > 0 {
+		card.Body = append([]cardElement{header}, card.Body...)
+	}
+	if n.ImageURL != "" {
+		card.Body = append(card.Body, cardElement{Type: "Image", URL: n.ImageURL})
+	}
+
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: adaptiveCardContentType,
+			Content:     card,
+		}},
+	}
+}
+
+// text converts mrkdwn to the Markdown subset of Adaptive Cards.
+func (t teamsTransport) text(s string) string {
+	return replaceEmoji(slackToMarkdown(s, t.users))
+}
+
+// accentStyle maps the color() buckets to Adaptive Card container styles.
+func accentStyle(color string) string {
+	switch color {
+	case "good":
+		return "good"
+	case "danger":
+		return "attention"
+	case "warning":
+		return "warning"
+	}
+	return "accent"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+)
+
+func TestTeamsTemplates(t *testing.T) {
+	release := func(t *testing.T, plugin *Plugin) {
+		fixture := newGitFixture(t)
+		fixture.commit("octocat@github.com", "initial commit", nil)
+		fixture.tag("v2.2.4", "v2.3.0")
+
+		plugin.Build.Event = "tag"
+		plugin.Build.Tag = "v2.3.0"
+		plugin.Config.GitPath = fixture.Dir
+	}
+	testReport := func(t *testing.T, plugin *Plugin) {
+		t.Setenv("DRONE_WORKSPACE", "testdata/gotest")
+		plugin.Config.GoTestReports = "report.json"
+		plugin.Config.CoverageProfiles = "coverage.out"
+		plugin.Config.CoverageBaseline = "baseline.txt"
+	}
+
+	testCases := []struct {
+		Template string
+		Status   string
+		Setup    func(t *testing.T, plugin *Plugin)
+	}{
+		{"basic_success_1", "success", nil},
+		{"basic_fail_1", "failure", nil},
+		{"success_tagged_deploy_1", "success", nil},
+		{"basic_on_hold_1", "blocked", nil},
+		{"release_1", "success", release},
+		{"test_report_1", "failure", testReport},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Template, func(t *testing.T) {
+			var body []byte
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				body, err = io.ReadAll(r.Body)
+				assert.NilError(t, err)
+				_, _ = w.Write([]byte("1"))
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(handler))
+			defer server.Close()
+
+			plugin := getTestPlugin()
+			plugin.Build.Status = testCase.Status
+			plugin.Build.Link = "https://drone.example.com/octocat/hello-world/1"
+			plugin.Build.Started = 1700000000
+			plugin.Config.Webhook = server.URL
+			plugin.Config.Transport = TransportTeams
+			plugin.Config.CustomTemplate = testCase.Template
+			plugin.Config.Mentions = "U123"
+			plugin.Config.UserMap = "U123=The Octocat"
+			if testCase.Setup != nil {
+				testCase.Setup(t, &plugin)
+			}
+
+			assert.NilError(t, plugin.Exec())
+
+			var indented bytes.Buffer
+			assert.NilError(t, json.Indent(&indented, body, "", "  "))
+			golden.Assert(t, indented.String()+"\n", "teams/"+testCase.Template+".golden")
+		})
+	}
+}
+
+func TestTeamsText(t *testing.T) {
+	transport := teamsTransport{}
+
+	payload := transport.payload(Notification{
+		Text:     "*failure* <http://ci/1|build 1> :x:",
+		Fallback: "failure build 1",
+		Color:    "danger",
+	})
+
+	card := payload.Attachments[0].Content
+	assert.Equal(t, payload.Attachments[0].ContentType, adaptiveCardContentType)
+	assert.Equal(t, card.FallbackText, "failure build 1")
+	assert.Equal(t, len(card.Body), 1)
+	assert.Equal(t, card.Body[0].Style, "attention")
+	assert.Equal(t, card.Body[0].Items[0].Text, "**failure** [build 1](http://ci/1) ❌")
+}
+
+func TestAccentStyle(t *testing.T) {
+	assert.Equal(t, accentStyle(color(Build{Status: "success"})), "good")
+	assert.Equal(t, accentStyle(color(Build{Status: "failure"})), "attention")
+	assert.Equal(t, accentStyle(color(Build{Status: "error"})), "attention")
+	assert.Equal(t, accentStyle(color(Build{Status: "blocked"})), "warning")
+	assert.Equal(t, accentStyle("#439FE0"), "accent")
+}
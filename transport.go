@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/slack-go/slack"
+)
+
+// Chat services notifications can be delivered to through a webhook.
+const (
+	TransportSlack      = "slack"
+	TransportMattermost = "mattermost"
+)
+
+// maxErrorBody bounds how much of an error response is included in errors.
+const maxErrorBody = 512
+
+type (
+	// Transport delivers a rendered notification through an incoming webhook.
+	Transport interface {
+		Send(n Notification) error
+	}
+
+	// Notification is a rendered message in Slack syntax. Transports for
+	// other services translate it to their own format.
+	Notification struct {
+		Channel   string
+		Username  string
+		IconURL   string
+		IconEmoji string
+		Text      string
+		Fallback  string
+		Color     string
+		ImageURL  string
+		Blocks    []slack.Block
+	}
+)
+
+// transport returns the transport of Config.Transport.
+func (p Plugin) transport() (Transport, error) {
+	users, err := parsePairs(p.Config.UserMap)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user map: %w", err)
+	}
+
+	switch p.Config.Transport {
+	case "", TransportSlack:
+		return slackTransport{webhook: p.Config.Webhook, client: p.httpClient()}, nil
+	case TransportMattermost:
+		return mattermostTransport{webhook: p.Config.Webhook, client: p.httpClient(), users: users}, nil
+	case TransportTeams:
+		return teamsTransport{webhook: p.Config.Webhook, client: p.httpClient(), users: users}, nil
+	case TransportDiscord:
+		return discordTransport{webhook: p.Config.Webhook, client: p.httpClient(), users: users}, nil
+	case TransportGoogleChat:
+		return googleChatTransport{webhook: p.Config.Webhook, client: p.httpClient(), users: users}, nil
+	}
+	return nil, fmt.Errorf("unknown transport %q", p.Config.Transport)
+}
+
+type slackTransport struct {
+	webhook string
+	client  *http.Client
+}
+
+func (t slackTransport) Send(n Notification) error {
+	payload := slack.WebhookMessage{
+		Username:  n.Username,
+		IconURL:   n.IconURL,
+		IconEmoji: n.IconEmoji,
+		Channel:   n.Channel,
+		Attachments: []slack.Attachment{{
+			Color:      n.Color,
+			ImageURL:   n.ImageURL,
+			MarkdownIn: []string{"text", "fallback"},
+			Text:       n.Text,
+			Fallback:   n.Fallback,
+		}},
+	}
+
+	// Add custom blocks to the payload if they exist
+	if len(n.Blocks) > 0 {
+		payload.Blocks = &slack.Blocks{
+			BlockSet: n.Blocks,
+		}
+	}
+
+	return slack.PostWebhookCustomHTTP(t.webhook, t.client, &payload)
+}
+
+// postJSON posts payload to url and fails on any status but 2xx.
+func postJSON(client *http.Client, url string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("could not encode payload: %w", err)
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// hexColor turns the Slack attachment colors into hex colors.
+func hexColor(color string) string {
+	switch color {
+	case "good":
+		return "#2eb886"
+	case "warning":
+		return "#daa038"
+	case "danger":
+		return "#a30200"
+	}
+	return color
+}
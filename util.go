@@ -2,59 +2,33 @@ package main
 
 import (
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
+	"strings"
 )
 
-func contents(str string) (string, error) {
-	// Check for the empty string
-	if str == "" {
-		return str, nil
-	}
-
-	isFilePath := false
-
-	// See if the string is referencing a URL
-	if u, err := url.Parse(str); err == nil {
-		switch u.Scheme {
-		case "http", "https":
-			res, err := http.Get(str)
-			if err != nil {
-				return "", err
-			}
-
-			defer res.Body.Close()
-			b, err := io.ReadAll(res.Body)
-			if err != nil {
-				return "", fmt.Errorf("could not read response: %w", err)
-			}
-
-			return string(b), nil
-
-		case "file":
-			// Fall through to file loading
-			str = u.Path
-			isFilePath = true
+// parsePairs reads a comma separated list of key=value pairs.
+func parsePairs(s string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
 		}
-	}
-
-	// See if the string is referencing a file
-	_, err := os.Stat(str)
-	if err == nil {
-		b, err := os.ReadFile(str)
-		if err != nil {
-			return "", fmt.Errorf("could not load file %s: %w", str, err)
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid pair %q, must be key=value", pair)
 		}
-
-		return string(b), nil
+		pairs[key] = value
 	}
+	return pairs, nil
+}
 
-	if isFilePath {
-		return "", fmt.Errorf("could not load file %s: %w", str, err)
+// droneWorkspace is the directory of the build, which relative paths of
+// reports, templates and the git repository are resolved in.
+func droneWorkspace() string {
+	if workspace := os.Getenv("DRONE_WORKSPACE"); workspace != "" {
+		return workspace
 	}
-
-	// Its a regular string
-	return str, nil
+	return "."
 }